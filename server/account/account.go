@@ -0,0 +1,455 @@
+// Package account exports everything a user owns as a versioned JSON archive
+// and restores such an archive into another, empty account, possibly on
+// another deployment.
+//
+// Archives never hold secrets: the password hash, webhook signing secrets and
+// inbound hook tokens are left out, and restored webhooks and inbound hooks
+// get new ones. Notifications, webhook deliveries, inbound event logs and
+// stored reports are logs or can be regenerated, so they are not exported.
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"habit-tracker/server/db"
+	"habit-tracker/server/models"
+	"habit-tracker/server/webhooks"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Format identifies account archives
+const Format = "habit-tracker/account"
+
+// Version is the archive layout written by Export. Restore accepts this
+// version only; older layouts are to be upgraded here when it changes.
+const Version = 1
+
+// ErrNotEmpty is returned when restoring into an account that already holds data
+var ErrNotEmpty = errors.New("the account already holds data")
+
+// Profile is the part of a user that is exported. The password hash is not.
+type Profile struct {
+	Name        string              `json:"name"`
+	Email       string              `json:"email"`
+	AvatarURL   string              `json:"avatar_url"`
+	Streak      int                 `json:"streak"`
+	XP          int                 `json:"xp"`
+	PointsSpent int                 `json:"points_spent"`
+	Settings    models.UserSettings `json:"settings"`
+	CreatedAt   time.Time           `json:"created_at"`
+}
+
+// Webhook is an exported webhook, without its signing secret
+type Webhook struct {
+	ID        primitive.ObjectID `json:"id"`
+	URL       string             `json:"url"`
+	Events    []string           `json:"events"`
+	Active    bool               `json:"active"`
+	CreatedAt time.Time          `json:"created_at"`
+}
+
+// InboundHook is an exported inbound hook, without its token
+type InboundHook struct {
+	ID        primitive.ObjectID   `json:"id"`
+	Name      string               `json:"name"`
+	Rules     []models.InboundRule `json:"rules"`
+	Active    bool                 `json:"active"`
+	CreatedAt time.Time            `json:"created_at"`
+}
+
+// Archive is everything a user owns. Tasks and habits in the trash are
+// included. IDs are those of the exporting deployment; they only serve to link
+// records within the archive.
+type Archive struct {
+	Format       string               `json:"format"`
+	Version      int                  `json:"version"`
+	ExportedAt   time.Time            `json:"exported_at"`
+	UserID       primitive.ObjectID   `json:"user_id"`
+	Profile      Profile              `json:"profile"`
+	Habits       []models.Habit       `json:"habits"`
+	Tasks        []models.Task        `json:"tasks"`
+	Relapses     []models.Relapse     `json:"relapses"`
+	Goals        []models.Goal        `json:"goals"`
+	Rewards      []models.Reward      `json:"rewards"`
+	Redemptions  []models.Redemption  `json:"redemptions"`
+	Points       []models.PointsEntry `json:"points"`
+	Achievements []models.Achievement `json:"achievements"`
+	Webhooks     []Webhook            `json:"webhooks"`
+	InboundHooks []InboundHook        `json:"inbound_hooks"`
+}
+
+// Summary counts the records restored from an archive. Warnings describe
+// references that could not be carried over.
+type Summary struct {
+	Habits       int      `json:"habits"`
+	Tasks        int      `json:"tasks"`
+	Relapses     int      `json:"relapses"`
+	Goals        int      `json:"goals"`
+	Rewards      int      `json:"rewards"`
+	Redemptions  int      `json:"redemptions"`
+	Points       int      `json:"points"`
+	Achievements int      `json:"achievements"`
+	Webhooks     int      `json:"webhooks"`
+	InboundHooks int      `json:"inbound_hooks"`
+	Warnings     []string `json:"warnings"`
+}
+
+// owned lists the collections holding a user's records, keyed by user_id
+func owned() []*mongo.Collection {
+	return []*mongo.Collection{
+		db.HabitColl, db.TaskColl, db.RelapseColl, db.GoalColl, db.RewardColl, db.RedemptionColl,
+		db.PointsColl, db.AchievementColl, db.WebhookColl, db.InboundHookColl,
+	}
+}
+
+// Export builds the archive of a user
+func Export(user models.User) (Archive, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	archive := Archive{
+		Format:     Format,
+		Version:    Version,
+		ExportedAt: time.Now(),
+		UserID:     user.ID,
+		Profile: Profile{
+			Name:        user.Name,
+			Email:       user.Email,
+			AvatarURL:   user.AvatarURL,
+			Streak:      user.Streak,
+			XP:          user.XP,
+			PointsSpent: user.PointsSpent,
+			Settings:    user.Settings,
+			CreatedAt:   user.CreatedAt,
+		},
+	}
+
+	filter := bson.M{"user_id": user.ID}
+	loads := []struct {
+		coll *mongo.Collection
+		into interface{}
+	}{
+		{db.HabitColl, &archive.Habits},
+		{db.TaskColl, &archive.Tasks},
+		{db.RelapseColl, &archive.Relapses},
+		{db.GoalColl, &archive.Goals},
+		{db.RewardColl, &archive.Rewards},
+		{db.RedemptionColl, &archive.Redemptions},
+		{db.PointsColl, &archive.Points},
+		{db.AchievementColl, &archive.Achievements},
+	}
+	for _, load := range loads {
+		cursor, err := load.coll.Find(ctx, filter)
+		if err != nil {
+			return Archive{}, err
+		}
+		if err := cursor.All(ctx, load.into); err != nil {
+			return Archive{}, err
+		}
+	}
+
+	var hooks []models.Webhook
+	cursor, err := db.WebhookColl.Find(ctx, filter)
+	if err != nil {
+		return Archive{}, err
+	}
+	if err := cursor.All(ctx, &hooks); err != nil {
+		return Archive{}, err
+	}
+	archive.Webhooks = make([]Webhook, 0, len(hooks))
+	for _, hook := range hooks {
+		archive.Webhooks = append(archive.Webhooks, Webhook{
+			ID: hook.ID, URL: hook.URL, Events: hook.Events, Active: hook.Active, CreatedAt: hook.CreatedAt,
+		})
+	}
+
+	var inbound []models.InboundHook
+	cursor, err = db.InboundHookColl.Find(ctx, filter)
+	if err != nil {
+		return Archive{}, err
+	}
+	if err := cursor.All(ctx, &inbound); err != nil {
+		return Archive{}, err
+	}
+	archive.InboundHooks = make([]InboundHook, 0, len(inbound))
+	for _, hook := range inbound {
+		archive.InboundHooks = append(archive.InboundHooks, InboundHook{
+			ID: hook.ID, Name: hook.Name, Rules: hook.Rules, Active: hook.Active, CreatedAt: hook.CreatedAt,
+		})
+	}
+
+	return archive, nil
+}
+
+// Check reports whether an archive can be restored by this version
+func Check(archive Archive) error {
+	if archive.Format != Format {
+		return fmt.Errorf("not an account archive: format must be %q", Format)
+	}
+	if archive.Version != Version {
+		return fmt.Errorf("unsupported archive version %d, expected %d", archive.Version, Version)
+	}
+	return nil
+}
+
+// IsEmpty reports whether a user owns no records yet
+func IsEmpty(userID primitive.ObjectID) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for _, coll := range owned() {
+		count, err := coll.CountDocuments(ctx, bson.M{"user_id": userID})
+		if err != nil {
+			return false, err
+		}
+		if count > 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Restore copies an archive into an empty account. Every record gets a new ID
+// and references between records are rewritten to match. The user's name,
+// email and password are kept; the rest of the profile comes from the archive.
+// Webhooks get new secrets and are restored inactive, so receivers can be
+// given the new secret before deliveries resume; inbound hooks get new tokens.
+// If a write fails, everything restored so far is removed again.
+func Restore(userID primitive.ObjectID, archive Archive) (Summary, error) {
+	if err := Check(archive); err != nil {
+		return Summary{}, err
+	}
+	empty, err := IsEmpty(userID)
+	if err != nil {
+		return Summary{}, err
+	}
+	if !empty {
+		return Summary{}, ErrNotEmpty
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	summary, documents, err := remap(userID, archive)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	for _, batch := range documents {
+		if len(batch.docs) == 0 {
+			continue
+		}
+		if _, err := batch.coll.InsertMany(ctx, batch.docs); err != nil {
+			rollback(ctx, userID)
+			return Summary{}, err
+		}
+	}
+
+	profile := bson.M{
+		"avatar_url":   archive.Profile.AvatarURL,
+		"streak":       archive.Profile.Streak,
+		"xp":           archive.Profile.XP,
+		"points_spent": archive.Profile.PointsSpent,
+		"settings":     archive.Profile.Settings,
+	}
+	if _, err := db.UserColl.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{"$set": profile}); err != nil {
+		rollback(ctx, userID)
+		return Summary{}, err
+	}
+
+	return summary, nil
+}
+
+// batch is a set of documents to insert into a collection
+type batch struct {
+	coll *mongo.Collection
+	docs []interface{}
+}
+
+// remap gives every archived record a new ID and the new owner, and rewrites
+// the references between them. References to records missing from the archive
+// are dropped and reported.
+func remap(userID primitive.ObjectID, archive Archive) (Summary, []batch, error) {
+	summary := Summary{Warnings: []string{}}
+	warn := func(format string, args ...interface{}) {
+		summary.Warnings = append(summary.Warnings, fmt.Sprintf(format, args...))
+	}
+
+	habitIDs := newIDs(len(archive.Habits), func(i int) primitive.ObjectID { return archive.Habits[i].ID })
+	taskIDs := newIDs(len(archive.Tasks), func(i int) primitive.ObjectID { return archive.Tasks[i].ID })
+	rewardIDs := newIDs(len(archive.Rewards), func(i int) primitive.ObjectID { return archive.Rewards[i].ID })
+
+	habits := batch{coll: db.HabitColl}
+	for _, habit := range archive.Habits {
+		habit.ID, habit.UserID = assign(habitIDs, habit.ID), userID
+		if habit.PredecessorID != nil {
+			if id, ok := habitIDs[*habit.PredecessorID]; ok {
+				habit.PredecessorID = &id
+			} else {
+				warn("habit %q: predecessor not in archive, unlinked", habit.Name)
+				habit.PredecessorID = nil
+			}
+		}
+		habits.docs = append(habits.docs, habit)
+	}
+
+	tasks := batch{coll: db.TaskColl}
+	for _, task := range archive.Tasks {
+		task.ID, task.UserID = assign(taskIDs, task.ID), userID
+		if task.HabitID != nil {
+			if id, ok := habitIDs[*task.HabitID]; ok {
+				task.HabitID = &id
+			} else {
+				warn("task %q on %s: habit not in archive, kept as a plain task", task.Name, task.Date)
+				task.HabitID = nil
+			}
+		}
+		tasks.docs = append(tasks.docs, task)
+	}
+
+	relapses := batch{coll: db.RelapseColl}
+	for _, relapse := range archive.Relapses {
+		id, ok := habitIDs[relapse.HabitID]
+		if !ok {
+			warn("relapse on %s: habit not in archive, skipped", relapse.Date)
+			continue
+		}
+		relapse.ID, relapse.UserID, relapse.HabitID = primitive.NewObjectID(), userID, id
+		relapses.docs = append(relapses.docs, relapse)
+	}
+
+	goals := batch{coll: db.GoalColl}
+	for _, goal := range archive.Goals {
+		goal.ID, goal.UserID = primitive.NewObjectID(), userID
+		ids := make([]primitive.ObjectID, 0, len(goal.HabitIDs))
+		for _, habitID := range goal.HabitIDs {
+			if id, ok := habitIDs[habitID]; ok {
+				ids = append(ids, id)
+			} else {
+				warn("goal %q: habit %s not in archive, removed from the goal", goal.Name, habitID.Hex())
+			}
+		}
+		goal.HabitIDs = ids
+		goals.docs = append(goals.docs, goal)
+	}
+
+	rewards := batch{coll: db.RewardColl}
+	for _, reward := range archive.Rewards {
+		reward.ID, reward.UserID = assign(rewardIDs, reward.ID), userID
+		rewards.docs = append(rewards.docs, reward)
+	}
+
+	// Redemptions and ledger entries outlive the rewards and tasks they point
+	// to, so missing targets are expected and left unlinked without a warning
+	redemptions := batch{coll: db.RedemptionColl}
+	for _, redemption := range archive.Redemptions {
+		redemption.ID, redemption.UserID, redemption.RewardID = primitive.NewObjectID(), userID, rewardIDs[redemption.RewardID]
+		redemptions.docs = append(redemptions.docs, redemption)
+	}
+
+	points := batch{coll: db.PointsColl}
+	for _, entry := range archive.Points {
+		entry.ID, entry.UserID, entry.TaskID = primitive.NewObjectID(), userID, taskIDs[entry.TaskID]
+		points.docs = append(points.docs, entry)
+	}
+
+	achievements := batch{coll: db.AchievementColl}
+	for _, achievement := range archive.Achievements {
+		achievement.ID, achievement.UserID = primitive.NewObjectID(), userID
+		achievements.docs = append(achievements.docs, achievement)
+	}
+
+	hooks := batch{coll: db.WebhookColl}
+	for _, hook := range archive.Webhooks {
+		secret, err := webhooks.NewSecret()
+		if err != nil {
+			return Summary{}, nil, err
+		}
+		hooks.docs = append(hooks.docs, models.Webhook{
+			ID:        primitive.NewObjectID(),
+			UserID:    userID,
+			URL:       hook.URL,
+			Events:    hook.Events,
+			Secret:    secret,
+			Active:    false,
+			CreatedAt: hook.CreatedAt,
+		})
+	}
+
+	inbound := batch{coll: db.InboundHookColl}
+	for _, hook := range archive.InboundHooks {
+		token, err := webhooks.NewSecret()
+		if err != nil {
+			return Summary{}, nil, err
+		}
+		rules := make([]models.InboundRule, 0, len(hook.Rules))
+		for _, rule := range hook.Rules {
+			id, ok := habitIDs[rule.HabitID]
+			if !ok {
+				warn("inbound hook %q: rule for habit %s not in archive, removed", hook.Name, rule.HabitID.Hex())
+				continue
+			}
+			rule.HabitID = id
+			rules = append(rules, rule)
+		}
+		inbound.docs = append(inbound.docs, models.InboundHook{
+			ID:        primitive.NewObjectID(),
+			UserID:    userID,
+			Name:      hook.Name,
+			Token:     token,
+			Rules:     rules,
+			Active:    hook.Active,
+			CreatedAt: hook.CreatedAt,
+		})
+	}
+
+	summary.Habits = len(habits.docs)
+	summary.Tasks = len(tasks.docs)
+	summary.Relapses = len(relapses.docs)
+	summary.Goals = len(goals.docs)
+	summary.Rewards = len(rewards.docs)
+	summary.Redemptions = len(redemptions.docs)
+	summary.Points = len(points.docs)
+	summary.Achievements = len(achievements.docs)
+	summary.Webhooks = len(hooks.docs)
+	summary.InboundHooks = len(inbound.docs)
+
+	return summary, []batch{habits, tasks, relapses, goals, rewards, redemptions, points, achievements, hooks, inbound}, nil
+}
+
+// newIDs maps the archived ID of each of n records to a new ID. Records
+// without an ID cannot be referenced and are left out.
+func newIDs(n int, id func(int) primitive.ObjectID) map[primitive.ObjectID]primitive.ObjectID {
+	ids := make(map[primitive.ObjectID]primitive.ObjectID, n)
+	for i := 0; i < n; i++ {
+		if old := id(i); !old.IsZero() {
+			ids[old] = primitive.NewObjectID()
+		}
+	}
+	return ids
+}
+
+// assign returns the new ID of an archived record
+func assign(ids map[primitive.ObjectID]primitive.ObjectID, old primitive.ObjectID) primitive.ObjectID {
+	if id, ok := ids[old]; ok {
+		return id
+	}
+	return primitive.NewObjectID()
+}
+
+// rollback removes everything a failed restore wrote. The account was empty
+// before, so every record it owns came from the restore.
+func rollback(ctx context.Context, userID primitive.ObjectID) {
+	for _, coll := range owned() {
+		if _, err := coll.DeleteMany(ctx, bson.M{"user_id": userID}); err != nil {
+			log.Printf("Error rolling back restore of user %s: %v", userID.Hex(), err)
+		}
+	}
+}
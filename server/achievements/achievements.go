@@ -0,0 +1,209 @@
+package achievements
+
+import (
+	"context"
+	"time"
+
+	"habit-tracker/server/db"
+	"habit-tracker/server/models"
+	"habit-tracker/server/streaks"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// dateLayout is the YYYY-MM-DD format used for task dates
+const dateLayout = "2006-01-02"
+
+// History is the task history that rules are evaluated against
+type History struct {
+	Tasks []models.Task
+	Days  map[string]streaks.DayInfo
+	Now   time.Time
+}
+
+// Rule unlocks an achievement once its check holds for a user's history
+type Rule struct {
+	Key         string                     `json:"key"`
+	Name        string                     `json:"name"`
+	Description string                     `json:"description"`
+	Check       func(history History) bool `json:"-"`
+}
+
+// Rules lists every achievement that can be unlocked
+var Rules = []Rule{
+	{
+		Key:         "first_7_day_streak",
+		Name:        "One Week Strong",
+		Description: "Complete all your tasks 7 days in a row",
+		Check:       func(h History) bool { return streaks.Longest(h.Days) >= 7 },
+	},
+	{
+		Key:         "100_completions",
+		Name:        "Centurion",
+		Description: "Complete 100 tasks",
+		Check:       func(h History) bool { return countCompletions(h.Tasks) >= 100 },
+	},
+	{
+		Key:         "perfect_month",
+		Name:        "Perfect Month",
+		Description: "Complete all your tasks every day of a calendar month",
+		Check:       hasPerfectMonth,
+	},
+	{
+		Key:         "comeback",
+		Name:        "Comeback",
+		Description: "Complete a full day again after a break of at least a week",
+		Check:       hasComeback,
+	},
+}
+
+// comebackGap is the number of idle days that make a perfect day a comeback
+const comebackGap = 7
+
+// Evaluate checks every rule against the user's tasks outside the trash and
+// records the achievements that are not unlocked yet. It returns the newly
+// unlocked ones.
+func Evaluate(userID primitive.ObjectID, tasks []models.Task) ([]models.Achievement, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	unlocked, err := Unlocked(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	history := History{Tasks: tasks, Days: streaks.GroupByDate(tasks), Now: time.Now()}
+
+	var newlyUnlocked []models.Achievement
+	for _, rule := range Rules {
+		if _, ok := unlocked[rule.Key]; ok || !rule.Check(history) {
+			continue
+		}
+
+		// The upsert only inserts once per user and key, so concurrent
+		// evaluations cannot unlock the same achievement twice
+		achievement := models.Achievement{UserID: userID, Key: rule.Key, UnlockedAt: history.Now}
+		result, err := db.AchievementColl.UpdateOne(
+			ctx,
+			bson.M{"user_id": userID, "key": rule.Key},
+			bson.M{"$setOnInsert": bson.M{"unlocked_at": achievement.UnlockedAt}},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			return nil, err
+		}
+		if result.UpsertedID != nil {
+			achievement.ID = result.UpsertedID.(primitive.ObjectID)
+			newlyUnlocked = append(newlyUnlocked, achievement)
+		}
+	}
+
+	return newlyUnlocked, nil
+}
+
+// Unlocked returns a user's unlocked achievements keyed by achievement key
+func Unlocked(userID primitive.ObjectID) (map[string]models.Achievement, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := db.AchievementColl.Find(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	var achievements []models.Achievement
+	if err := cursor.All(ctx, &achievements); err != nil {
+		return nil, err
+	}
+
+	byKey := make(map[string]models.Achievement, len(achievements))
+	for _, achievement := range achievements {
+		byKey[achievement.Key] = achievement
+	}
+	return byKey, nil
+}
+
+// countCompletions counts completed tasks, leaving out freeze markers
+func countCompletions(tasks []models.Task) int {
+	count := 0
+	for _, task := range tasks {
+		if task.Completed && !streaks.IsFrozenTask(task) {
+			count++
+		}
+	}
+	return count
+}
+
+// hasPerfectMonth reports whether some finished calendar month had every day
+// either perfect or frozen, with at least one perfect day
+func hasPerfectMonth(h History) bool {
+	days := streaks.SortedDays(h.Days)
+	if len(days) == 0 {
+		return false
+	}
+
+	first, err := time.Parse(dateLayout, days[0])
+	if err != nil {
+		return false
+	}
+	today := h.Now.Format(dateLayout)
+
+	for month := time.Date(first.Year(), first.Month(), 1, 0, 0, 0, 0, time.UTC); ; month = month.AddDate(0, 1, 0) {
+		lastDay := month.AddDate(0, 1, -1)
+		if lastDay.Format(dateLayout) > today {
+			return false
+		}
+
+		perfect := true
+		anyPerfect := false
+		for day := month; !day.After(lastDay); day = day.AddDate(0, 0, 1) {
+			info := h.Days[day.Format(dateLayout)]
+			if info.Perfect() {
+				anyPerfect = true
+			} else if !info.Frozen {
+				perfect = false
+				break
+			}
+		}
+		if perfect && anyPerfect {
+			return true
+		}
+	}
+}
+
+// hasComeback reports whether a perfect day followed at least comebackGap days
+// without any completed task, after the user had been active before
+func hasComeback(h History) bool {
+	days := streaks.SortedDays(h.Days)
+	if len(days) == 0 {
+		return false
+	}
+
+	first, errFirst := time.Parse(dateLayout, days[0])
+	last, errLast := time.Parse(dateLayout, days[len(days)-1])
+	if errFirst != nil || errLast != nil {
+		return false
+	}
+
+	activeBefore := false
+	idle := 0
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		info := h.Days[day.Format(dateLayout)]
+		switch {
+		case info.Perfect():
+			if activeBefore && idle >= comebackGap {
+				return true
+			}
+			activeBefore = true
+			idle = 0
+		case info.Completed > 0:
+			activeBefore = true
+			idle = 0
+		case info.Frozen:
+		default:
+			idle++
+		}
+	}
+	return false
+}
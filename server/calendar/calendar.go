@@ -0,0 +1,281 @@
+// Package calendar renders a user's habits and tasks as an iCalendar (RFC 5545)
+// feed. Active habits become recurring events following their schedule, at
+// their first reminder time or as all-day events. Other tasks become to-dos
+// due on their day, completed or not; a habit's daily tasks carry whether it
+// was done that day and are linked to its event. UIDs are derived from record
+// IDs, so calendar clients update entries on refresh instead of duplicating
+// them.
+package calendar
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"habit-tracker/server/models"
+	"habit-tracker/server/schedule"
+)
+
+const (
+	// domain qualifies UIDs so they are globally unique
+	domain = "habit-tracker"
+	// eventDuration is the length of timed habit events
+	eventDuration = "PT15M"
+	// lineLimit is the longest content line, in octets, before it is folded
+	lineLimit = 75
+)
+
+// Date and time formats of iCalendar values
+const (
+	dateFormat  = "20060102"
+	localFormat = "20060102T150405"
+	utcFormat   = "20060102T150405Z"
+)
+
+// weekdayLetters holds the two-letter weekday codes of RRULE, Sunday first
+const weekdayLetters = "SUMOTUWETHFRSA"
+
+// Feed renders the calendar of a user. loc is the user's time zone; when it
+// has no IANA name, timed events float in the reader's local time. now stamps
+// every entry and picks the years the time zone definition covers.
+func Feed(user models.User, habits []models.Habit, tasks []models.Task, loc *time.Location, now time.Time) string {
+	var lines []string
+	add := func(line string) {
+		lines = append(lines, line)
+	}
+
+	add("BEGIN:VCALENDAR")
+	add("VERSION:2.0")
+	add("PRODID:-//Habit Tracker//Calendar Feed//EN")
+	add("CALSCALE:GREGORIAN")
+	add("METHOD:PUBLISH")
+	add("X-WR-CALNAME:" + escape(user.Name+"'s habits"))
+
+	tzid := ""
+	if name := loc.String(); name != "Local" && name != "" {
+		tzid = name
+		add("X-WR-TIMEZONE:" + tzid)
+		lines = append(lines, timezone(loc, now)...)
+	}
+
+	stamp := "DTSTAMP:" + now.UTC().Format(utcFormat)
+	for _, habit := range habits {
+		lines = append(lines, habitEvent(habit, loc, tzid, stamp)...)
+	}
+	for _, task := range tasks {
+		lines = append(lines, taskTodo(task, stamp)...)
+	}
+
+	add("END:VCALENDAR")
+
+	var out strings.Builder
+	for _, line := range lines {
+		out.WriteString(fold(line))
+	}
+	return out.String()
+}
+
+// habitEvent renders a habit as an event recurring on its scheduled days
+func habitEvent(habit models.Habit, loc *time.Location, tzid, stamp string) []string {
+	start, err := time.ParseInLocation(schedule.DateLayout, habit.StartDate, loc)
+	if err != nil {
+		start = habit.CreatedAt.In(loc)
+		start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc)
+	}
+	// The first occurrence is DTSTART itself, so it must be a scheduled day
+	for i := 0; i < 7 && !schedule.IsScheduledOn(habit, start); i++ {
+		start = start.AddDate(0, 0, 1)
+	}
+
+	lines := []string{
+		"BEGIN:VEVENT",
+		"UID:habit-" + habit.ID.Hex() + "@" + domain,
+		stamp,
+	}
+	lines = append(lines, revision(habit.CreatedAt, habit.UpdatedAt, habit.Revision)...)
+	lines = append(lines, "SUMMARY:"+escape(habit.Name))
+
+	reminder := firstReminder(habit.Reminders)
+	at, err := schedule.At(start, reminder)
+	switch {
+	case reminder == "" || err != nil:
+		lines = append(lines,
+			"DTSTART;VALUE=DATE:"+start.Format(dateFormat),
+			"DURATION:P1D",
+			"TRANSP:TRANSPARENT")
+	case tzid != "":
+		lines = append(lines, "DTSTART;TZID="+tzid+":"+at.Format(localFormat), "DURATION:"+eventDuration)
+	default:
+		lines = append(lines, "DTSTART:"+at.Format(localFormat), "DURATION:"+eventDuration)
+	}
+
+	rule := "RRULE:FREQ=DAILY"
+	if len(habit.Weekdays) > 0 {
+		days := make([]string, 0, len(habit.Weekdays))
+		for _, weekday := range habit.Weekdays {
+			if weekday >= 0 && weekday <= 6 {
+				days = append(days, weekdayLetters[weekday*2:weekday*2+2])
+			}
+		}
+		rule = "RRULE:FREQ=WEEKLY;BYDAY=" + strings.Join(days, ",")
+	}
+	lines = append(lines, rule)
+
+	description := fmt.Sprintf("%s habit", habit.Type)
+	if habit.Target > 0 {
+		description += fmt.Sprintf(", target %g %s", habit.Target, habit.Unit)
+	}
+	if habit.Category != "" {
+		lines = append(lines, "CATEGORIES:"+escape(habit.Category))
+	}
+	lines = append(lines, "DESCRIPTION:"+escape(strings.TrimSpace(description)), "END:VEVENT")
+	return lines
+}
+
+// taskTodo renders a task as a to-do due on its day
+func taskTodo(task models.Task, stamp string) []string {
+	lines := []string{
+		"BEGIN:VTODO",
+		"UID:task-" + task.ID.Hex() + "@" + domain,
+		stamp,
+	}
+	lines = append(lines, revision(task.CreatedAt, task.UpdatedAt, task.Revision)...)
+	lines = append(lines, "SUMMARY:"+escape(task.Name))
+
+	if due, err := time.Parse(time.RFC3339, task.Date); err == nil {
+		lines = append(lines, "DUE:"+due.UTC().Format(utcFormat))
+	} else if due, err := time.Parse(schedule.DateLayout, task.Date); err == nil {
+		lines = append(lines, "DUE;VALUE=DATE:"+due.Format(dateFormat))
+	}
+
+	if task.Completed {
+		lines = append(lines, "STATUS:COMPLETED", "PERCENT-COMPLETE:100")
+	} else {
+		lines = append(lines, "STATUS:NEEDS-ACTION")
+	}
+	if task.HabitID != nil {
+		lines = append(lines, "RELATED-TO:habit-"+task.HabitID.Hex()+"@"+domain)
+	}
+	if task.Note != "" {
+		lines = append(lines, "DESCRIPTION:"+escape(task.Note))
+	}
+	return append(lines, "END:VTODO")
+}
+
+// revision renders when a record was last changed and its SEQUENCE, the
+// number of times it was updated
+func revision(created time.Time, updated *time.Time, sequence int) []string {
+	modified := created
+	if updated != nil && updated.After(created) {
+		modified = *updated
+	}
+	return []string{
+		"LAST-MODIFIED:" + modified.UTC().Format(utcFormat),
+		"SEQUENCE:" + strconv.Itoa(sequence),
+	}
+}
+
+// firstReminder returns the earliest reminder time, or "" when there is none
+func firstReminder(reminders []string) string {
+	if len(reminders) == 0 {
+		return ""
+	}
+	sorted := append([]string(nil), reminders...)
+	sort.Strings(sorted)
+	return sorted[0]
+}
+
+// timezone renders a VTIMEZONE for loc covering the year before now to two
+// years after it. Each offset change in that span is listed as its own
+// observance, which avoids guessing the zone's recurrence rules.
+func timezone(loc *time.Location, now time.Time) []string {
+	lines := []string{"BEGIN:VTIMEZONE", "TZID:" + loc.String()}
+
+	from := time.Date(now.Year()-1, time.January, 1, 0, 0, 0, 0, loc)
+	to := time.Date(now.Year()+3, time.January, 1, 0, 0, 0, 0, loc)
+
+	name, offset := from.Zone()
+	transitions := 0
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		next := day.AddDate(0, 0, 1)
+		if _, nextOffset := next.Zone(); nextOffset == offset {
+			continue
+		}
+		// Narrow the change down to the second
+		low, high := day.Unix(), next.Unix()
+		for high-low > 1 {
+			middle := (low + high) / 2
+			if _, middleOffset := time.Unix(middle, 0).In(loc).Zone(); middleOffset == offset {
+				low = middle
+			} else {
+				high = middle
+			}
+		}
+		change := time.Unix(high, 0).In(loc)
+		newName, newOffset := change.Zone()
+		before := change.In(time.FixedZone(name, offset))
+		lines = append(lines, observance(before, newName, offset, newOffset, newOffset > offset)...)
+		name, offset = newName, newOffset
+		transitions++
+	}
+
+	if transitions == 0 {
+		lines = append(lines, observance(from, name, offset, offset, false)...)
+	}
+	return append(lines, "END:VTIMEZONE")
+}
+
+// observance renders a STANDARD or DAYLIGHT block starting at the local time
+// start, expressed in the offset in force before it
+func observance(start time.Time, name string, from, to int, daylight bool) []string {
+	kind := "STANDARD"
+	if daylight {
+		kind = "DAYLIGHT"
+	}
+	return []string{
+		"BEGIN:" + kind,
+		"DTSTART:" + start.Format(localFormat),
+		"TZOFFSETFROM:" + formatOffset(from),
+		"TZOFFSETTO:" + formatOffset(to),
+		"TZNAME:" + escape(name),
+		"END:" + kind,
+	}
+}
+
+// formatOffset formats a UTC offset in seconds as +HHMM
+func formatOffset(seconds int) string {
+	sign := "+"
+	if seconds < 0 {
+		sign = "-"
+		seconds = -seconds
+	}
+	return fmt.Sprintf("%s%02d%02d", sign, seconds/3600, seconds/60%60)
+}
+
+// escape escapes a TEXT value
+func escape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(value)
+}
+
+// fold splits a content line into lines of at most lineLimit octets, without
+// breaking UTF-8 sequences, and terminates it with CRLF
+func fold(line string) string {
+	var out strings.Builder
+	limit := lineLimit
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		out.WriteString(line[:cut])
+		out.WriteString("\r\n ")
+		line = line[cut:]
+		// Continuation lines start with a space, which counts towards the limit
+		limit = lineLimit - 1
+	}
+	out.WriteString(line)
+	out.WriteString("\r\n")
+	return out.String()
+}
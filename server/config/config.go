@@ -0,0 +1,22 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// TrashRetention returns how long deleted tasks and habits stay restorable,
+// read from TRASH_RETENTION_DAYS (30 days by default)
+func TrashRetention() time.Duration {
+	return time.Duration(intFromEnv("TRASH_RETENTION_DAYS", 30)) * 24 * time.Hour
+}
+
+// intFromEnv reads a positive integer environment variable, falling back to def
+func intFromEnv(key string, def int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return def
+	}
+	return value
+}
@@ -6,14 +6,29 @@ import (
 	"os"
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var (
-	Client   *mongo.Client
-	UserColl *mongo.Collection
-	TaskColl *mongo.Collection
+	Client           *mongo.Client
+	UserColl         *mongo.Collection
+	TaskColl         *mongo.Collection
+	HabitColl        *mongo.Collection
+	RelapseColl      *mongo.Collection
+	GoalColl         *mongo.Collection
+	AchievementColl  *mongo.Collection
+	PointsColl       *mongo.Collection
+	RewardColl       *mongo.Collection
+	RedemptionColl   *mongo.Collection
+	LeaseColl        *mongo.Collection
+	NotificationColl *mongo.Collection
+	WebhookColl      *mongo.Collection
+	DeliveryColl     *mongo.Collection
+	InboundHookColl  *mongo.Collection
+	InboundEventColl *mongo.Collection
+	ReportColl       *mongo.Collection
 )
 
 // Init initializes the database connection
@@ -43,6 +58,110 @@ func Init() {
 	database := Client.Database("habit_tracker")
 	UserColl = database.Collection("users")
 	TaskColl = database.Collection("tasks")
+	HabitColl = database.Collection("habits")
+	RelapseColl = database.Collection("relapses")
+	GoalColl = database.Collection("goals")
+	AchievementColl = database.Collection("achievements")
+	PointsColl = database.Collection("points_ledger")
+	RewardColl = database.Collection("rewards")
+	RedemptionColl = database.Collection("redemptions")
+	LeaseColl = database.Collection("leases")
+	NotificationColl = database.Collection("notifications")
+	WebhookColl = database.Collection("webhooks")
+	DeliveryColl = database.Collection("webhook_deliveries")
+	InboundHookColl = database.Collection("inbound_hooks")
+	InboundEventColl = database.Collection("inbound_events")
+	ReportColl = database.Collection("reports")
+
+	if err := createIndexes(ctx); err != nil {
+		log.Fatal("Error creating MongoDB indexes:", err)
+	}
 
 	log.Println("Connected to MongoDB!")
 }
+
+// createIndexes creates the indexes the handlers rely on
+func createIndexes(ctx context.Context) error {
+	// Each achievement is unlocked at most once per user
+	_, err := AchievementColl.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "key", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Ledger entries of a task are numbered, so two concurrent syncs of the
+	// same task cannot both record the same adjustment. Entries written
+	// before numbering have no seq.
+	_, err = PointsColl.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "task_id", Value: 1}, {Key: "seq", Value: 1}},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"seq": bson.M{"$exists": true}}),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Expired leases are removed by MongoDB
+	_, err = LeaseColl.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Inbox pages and unread counts
+	_, err = NotificationColl.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "read_at", Value: 1}, {Key: "created_at", Value: -1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// The delivery queue and per-webhook delivery logs
+	_, err = DeliveryColl.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}}},
+		{Keys: bson.D{{Key: "webhook_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Calendar feeds are looked up by token; users without a feed have none
+	_, err = UserColl.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "calendar_token", Value: 1}},
+		Options: options.Index().SetUnique(true).SetSparse(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Inbound hooks are looked up by token, and a replayed event is recognized
+	// by its delivery key
+	_, err = InboundHookColl.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "token", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = InboundEventColl.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "hook_id", Value: 1}, {Key: "delivery_key", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "hook_id", Value: 1}, {Key: "received_at", Value: -1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// A stored report exists once per user and period
+	_, err = ReportColl.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "period", Value: 1}, {Key: "start_date", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
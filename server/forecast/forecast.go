@@ -0,0 +1,247 @@
+// Package forecast predicts how likely a user is to complete each habit on
+// upcoming days and when a streak or completion count is likely reached.
+//
+// The model is a recency-weighted Beta-Bernoulli rate per weekday. Each past
+// task of a habit is a Bernoulli trial weighted by 0.5^(age/HalfLife), so a
+// task from HalfLife days ago counts half as much as one from today. The
+// habit's overall rate p uses a uniform Beta(1, 1) prior. Each weekday's rate
+// uses a Beta(PriorStrength*p, PriorStrength*(1-p)) prior, so weekdays with
+// little history lean on the habit's overall rate:
+//
+//	P(weekday) = (PriorStrength*p + weighted completions) / (PriorStrength + weighted tasks)
+//
+// Targets are projected by following the probability distribution of the
+// streak or count day by day, treating days as independent. The expected
+// date is the first day on which the target has been reached with at least
+// even odds.
+package forecast
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"habit-tracker/server/models"
+	"habit-tracker/server/schedule"
+)
+
+const (
+	// HalfLife is the age in days at which a task counts half as much
+	HalfLife = 28.0
+	// PriorStrength is how many tasks the habit's overall rate is worth in a
+	// weekday's estimate
+	PriorStrength = 2.0
+	// Horizon is how many days ahead targets are projected
+	Horizon = 366
+)
+
+// Day is the predicted chance of completing a habit on a scheduled day
+type Day struct {
+	Date        string  `json:"date"`
+	Probability float64 `json:"probability"`
+}
+
+// Target is the projection of a streak or completion count target. Current is
+// where the habit stands today, ExpectedDate the first day the target is
+// reached with at least even odds (empty when reached already or not within
+// the horizon) and Probability the chance of reaching it within the horizon.
+type Target struct {
+	Target       int     `json:"target"`
+	Current      int     `json:"current"`
+	Reached      bool    `json:"reached"`
+	ExpectedDate string  `json:"expected_date,omitempty"`
+	Probability  float64 `json:"probability"`
+}
+
+// Habit is the forecast of a single habit
+type Habit struct {
+	HabitID      string     `json:"habit_id"`
+	Name         string     `json:"name"`
+	Rate         float64    `json:"rate"`
+	WeekdayRates [7]float64 `json:"weekday_rates"`
+	Days         []Day      `json:"days"`
+	Streak       *Target    `json:"streak,omitempty"`
+	Count        *Target    `json:"count,omitempty"`
+}
+
+// Model holds the estimated completion rates of a habit
+type Model struct {
+	Rate     float64
+	Weekdays [7]float64
+}
+
+// Fit estimates a habit's completion rates from its past tasks as of today
+func Fit(tasks []models.Task, today time.Time) Model {
+	var weights, completions [7]float64
+	var totalWeight, totalCompleted float64
+	for _, task := range tasks {
+		// Today's open task is not a miss yet
+		day, ok := taskDay(task, today.Location())
+		if !ok || day.After(today) || day.Equal(today) && !task.Completed {
+			continue
+		}
+		age := today.Sub(day).Hours() / 24
+		weight := math.Pow(0.5, age/HalfLife)
+		weights[day.Weekday()] += weight
+		totalWeight += weight
+		if task.Completed {
+			completions[day.Weekday()] += weight
+			totalCompleted += weight
+		}
+	}
+
+	model := Model{Rate: (1 + totalCompleted) / (2 + totalWeight)}
+	for weekday := range model.Weekdays {
+		model.Weekdays[weekday] = (PriorStrength*model.Rate + completions[weekday]) / (PriorStrength + weights[weekday])
+	}
+	return model
+}
+
+// Probability returns the chance of completing the habit on a day
+func (m Model) Probability(day time.Time) float64 {
+	return m.Weekdays[day.Weekday()]
+}
+
+// Forecast predicts a habit over the next days, starting today, from its past
+// tasks. Days the habit is not scheduled on, and today once completed, are
+// left out. Streak and count targets are projected when positive.
+func Forecast(habit models.Habit, tasks []models.Task, today time.Time, days, streakTarget, countTarget int) Habit {
+	model := Fit(tasks, today)
+	result := Habit{
+		HabitID: habit.ID.Hex(),
+		Name:    habit.Name,
+		Rate:    round(model.Rate),
+		Days:    []Day{},
+	}
+	for weekday, rate := range model.Weekdays {
+		result.WeekdayRates[weekday] = round(rate)
+	}
+
+	upcoming := upcomingDays(habit, tasks, today)
+	for _, day := range upcoming[:min(days, len(upcoming))] {
+		result.Days = append(result.Days, Day{Date: day.Format(schedule.DateLayout), Probability: round(model.Probability(day))})
+	}
+
+	if streakTarget > 0 {
+		target := project(currentStreak(tasks, today), streakTarget, upcoming, model, true)
+		result.Streak = &target
+	}
+	if countTarget > 0 {
+		target := project(completedCount(tasks), countTarget, upcoming, model, false)
+		result.Count = &target
+	}
+	return result
+}
+
+// upcomingDays lists the habit's scheduled days within the horizon, skipping
+// today when its task is already completed
+func upcomingDays(habit models.Habit, tasks []models.Task, today time.Time) []time.Time {
+	doneToday := false
+	for _, task := range tasks {
+		if day, ok := taskDay(task, today.Location()); ok && day.Equal(today) && task.Completed {
+			doneToday = true
+		}
+	}
+
+	upcoming := []time.Time{}
+	for offset := 0; offset < Horizon; offset++ {
+		day := today.AddDate(0, 0, offset)
+		if offset == 0 && doneToday || !schedule.IsScheduledOn(habit, day) {
+			continue
+		}
+		upcoming = append(upcoming, day)
+	}
+	return upcoming
+}
+
+// project follows the distribution of a streak or count over the upcoming
+// days. states[i] is the chance of standing at i; reaching the target absorbs.
+// A missed day resets a streak and leaves a count unchanged.
+func project(current, target int, upcoming []time.Time, model Model, streak bool) Target {
+	result := Target{Target: target, Current: current}
+	if current >= target {
+		result.Reached = true
+		result.Probability = 1
+		return result
+	}
+
+	states := make([]float64, target)
+	states[current] = 1
+	reached := 0.0
+	for _, day := range upcoming {
+		p := model.Probability(day)
+		next := make([]float64, target)
+		for i, chance := range states {
+			if i+1 == target {
+				reached += chance * p
+			} else {
+				next[i+1] += chance * p
+			}
+			if streak {
+				next[0] += chance * (1 - p)
+			} else {
+				next[i] += chance * (1 - p)
+			}
+		}
+		states = next
+		if result.ExpectedDate == "" && reached >= 0.5 {
+			result.ExpectedDate = day.Format(schedule.DateLayout)
+		}
+	}
+
+	result.Probability = round(reached)
+	return result
+}
+
+// currentStreak counts the habit's completed tasks in a row up to today. An
+// open task today does not break the streak, since the day is not over.
+func currentStreak(tasks []models.Task, today time.Time) int {
+	byDay := make(map[string]bool)
+	for _, task := range tasks {
+		if day, ok := taskDay(task, today.Location()); ok && !day.After(today) {
+			key := day.Format(schedule.DateLayout)
+			byDay[key] = byDay[key] || task.Completed
+		}
+	}
+
+	dates := make([]string, 0, len(byDay))
+	for date := range byDay {
+		dates = append(dates, date)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(dates)))
+
+	streak := 0
+	for i, date := range dates {
+		if byDay[date] {
+			streak++
+		} else if i > 0 || date != today.Format(schedule.DateLayout) {
+			break
+		}
+	}
+	return streak
+}
+
+// completedCount counts the habit's completed tasks
+func completedCount(tasks []models.Task) int {
+	count := 0
+	for _, task := range tasks {
+		if task.Completed {
+			count++
+		}
+	}
+	return count
+}
+
+// taskDay parses the day of a task in loc
+func taskDay(task models.Task, loc *time.Location) (time.Time, bool) {
+	if len(task.Date) < len(schedule.DateLayout) {
+		return time.Time{}, false
+	}
+	day, err := time.ParseInLocation(schedule.DateLayout, task.Date[:len(schedule.DateLayout)], loc)
+	return day, err == nil
+}
+
+// round rounds a probability to four decimal places
+func round(value float64) float64 {
+	return math.Round(value*10000) / 10000
+}
@@ -0,0 +1,215 @@
+package forecast
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"habit-tracker/server/models"
+	"habit-tracker/server/schedule"
+)
+
+// today is a Wednesday
+var today = time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC)
+
+// history builds a habit's tasks for the given number of days before today,
+// on the days scheduled reports, completed as done reports
+func history(days int, scheduled, done func(day time.Time) bool) []models.Task {
+	tasks := []models.Task{}
+	for offset := days; offset >= 1; offset-- {
+		day := today.AddDate(0, 0, -offset)
+		if !scheduled(day) {
+			continue
+		}
+		tasks = append(tasks, models.Task{Date: day.Format(schedule.DateLayout), Completed: done(day)})
+	}
+	return tasks
+}
+
+func always(time.Time) bool { return true }
+func never(time.Time) bool  { return false }
+
+// withinDays reports whether a day is less than n days before today
+func withinDays(n int) func(day time.Time) bool {
+	return func(day time.Time) bool { return today.Sub(day) < time.Duration(n)*24*time.Hour }
+}
+
+// monWedFri reports whether a day is a Monday, Wednesday or Friday
+func monWedFri(day time.Time) bool {
+	weekday := day.Weekday()
+	return weekday == time.Monday || weekday == time.Wednesday || weekday == time.Friday
+}
+
+// everyDay is the model of a habit that is always or never completed
+func everyDay(p float64) Model {
+	model := Model{Rate: p}
+	for weekday := range model.Weekdays {
+		model.Weekdays[weekday] = p
+	}
+	return model
+}
+
+// nextDays lists n consecutive days starting today
+func nextDays(n int) []time.Time {
+	days := make([]time.Time, n)
+	for i := range days {
+		days[i] = today.AddDate(0, 0, i)
+	}
+	return days
+}
+
+func TestFitWithoutHistory(t *testing.T) {
+	model := Fit(nil, today)
+	if model != everyDay(0.5) {
+		t.Errorf("Fit(nil) = %+v, want every rate at 0.5", model)
+	}
+}
+
+func TestFitAllDone(t *testing.T) {
+	model := Fit(history(90, always, always), today)
+	if model.Rate < 0.95 {
+		t.Errorf("Rate = %.4f, want at least 0.95", model.Rate)
+	}
+	for weekday, rate := range model.Weekdays {
+		if rate < 0.9 {
+			t.Errorf("Weekdays[%d] = %.4f, want at least 0.9", weekday, rate)
+		}
+	}
+}
+
+func TestFitNoneDone(t *testing.T) {
+	model := Fit(history(90, always, never), today)
+	if model.Rate > 0.05 {
+		t.Errorf("Rate = %.4f, want at most 0.05", model.Rate)
+	}
+	for weekday, rate := range model.Weekdays {
+		if rate > 0.1 {
+			t.Errorf("Weekdays[%d] = %.4f, want at most 0.1", weekday, rate)
+		}
+	}
+}
+
+func TestFitWeighsRecentDropOff(t *testing.T) {
+	// Both histories miss the same number of days, recently or long ago
+	recentMisses := Fit(history(90, always, func(day time.Time) bool { return !withinDays(15)(day) }), today)
+	oldMisses := Fit(history(90, always, func(day time.Time) bool { return withinDays(76)(day) }), today)
+
+	// Unweighted, both would complete 76 of 90 days, about 0.84
+	if recentMisses.Rate >= 0.7 {
+		t.Errorf("Rate after a recent drop-off = %.4f, want below 0.7", recentMisses.Rate)
+	}
+	if oldMisses.Rate <= 0.9 {
+		t.Errorf("Rate after old misses = %.4f, want above 0.9", oldMisses.Rate)
+	}
+}
+
+func TestFitUnscheduledDays(t *testing.T) {
+	model := Fit(history(90, monWedFri, always), today)
+
+	for _, weekday := range []time.Weekday{time.Monday, time.Wednesday, time.Friday} {
+		if model.Weekdays[weekday] < 0.9 {
+			t.Errorf("Weekdays[%s] = %.4f, want at least 0.9", weekday, model.Weekdays[weekday])
+		}
+	}
+	// Weekdays without tasks fall back to the overall rate
+	for _, weekday := range []time.Weekday{time.Sunday, time.Tuesday, time.Thursday, time.Saturday} {
+		if math.Abs(model.Weekdays[weekday]-model.Rate) > 1e-9 {
+			t.Errorf("Weekdays[%s] = %.4f, want the overall rate %.4f", weekday, model.Weekdays[weekday], model.Rate)
+		}
+	}
+}
+
+func TestFitIgnoresOpenTaskToday(t *testing.T) {
+	tasks := history(30, always, always)
+	open := append(tasks, models.Task{Date: today.Format(schedule.DateLayout)})
+	if Fit(open, today) != Fit(tasks, today) {
+		t.Error("an open task today changed the model")
+	}
+}
+
+func TestCurrentStreak(t *testing.T) {
+	openToday := models.Task{Date: today.Format(schedule.DateLayout)}
+	doneToday := models.Task{Date: today.Format(schedule.DateLayout), Completed: true}
+
+	tests := []struct {
+		name  string
+		tasks []models.Task
+		want  int
+	}{
+		{"no history", nil, 0},
+		{"all done", history(20, always, always), 20},
+		{"none done", history(20, always, never), 0},
+		{"recent drop-off", history(20, always, func(day time.Time) bool { return !withinDays(3)(day) }), 0},
+		{"missed a week ago", history(20, always, func(day time.Time) bool { return withinDays(7)(day) }), 6},
+		{"unscheduled gaps", history(21, monWedFri, always), 9},
+		{"open today", append(history(5, always, always), openToday), 5},
+		{"done today", append(history(5, always, always), doneToday), 6},
+	}
+	for _, test := range tests {
+		if got := currentStreak(test.tasks, today); got != test.want {
+			t.Errorf("%s: currentStreak() = %d, want %d", test.name, got, test.want)
+		}
+	}
+}
+
+func TestProjectCertain(t *testing.T) {
+	target := project(2, 5, nextDays(10), everyDay(1), true)
+	want := Target{Target: 5, Current: 2, ExpectedDate: "2024-05-17", Probability: 1}
+	if target != want {
+		t.Errorf("project() = %+v, want %+v", target, want)
+	}
+}
+
+func TestProjectImpossible(t *testing.T) {
+	target := project(0, 3, nextDays(30), everyDay(0), false)
+	want := Target{Target: 3}
+	if target != want {
+		t.Errorf("project() = %+v, want %+v", target, want)
+	}
+}
+
+func TestProjectReached(t *testing.T) {
+	target := project(7, 7, nextDays(10), everyDay(0.5), true)
+	if !target.Reached || target.Probability != 1 || target.ExpectedDate != "" {
+		t.Errorf("project() = %+v, want the target reached", target)
+	}
+}
+
+func TestProjectStreakVersusCount(t *testing.T) {
+	model := everyDay(0.5)
+	streak := project(0, 3, nextDays(7), model, true)
+	count := project(0, 3, nextDays(7), model, false)
+
+	// Three in a row within a week: 1 - P(no run of three in seven fair coin
+	// flips) = 1 - 81/128
+	if want := round(47.0 / 128); streak.Probability != want {
+		t.Errorf("streak probability = %.4f, want %.4f", streak.Probability, want)
+	}
+	// At least three of seven: 1 - (1 + 7 + 21)/128
+	if want := round(99.0 / 128); count.Probability != want {
+		t.Errorf("count probability = %.4f, want %.4f", count.Probability, want)
+	}
+	if streak.ExpectedDate != "" {
+		t.Errorf("streak expected date = %s, want none below even odds", streak.ExpectedDate)
+	}
+	// P(at least three of the first five) = 16/32
+	if count.ExpectedDate != "2024-05-19" {
+		t.Errorf("count expected date = %s, want 2024-05-19", count.ExpectedDate)
+	}
+}
+
+func TestForecastSkipsUnscheduledAndCompletedDays(t *testing.T) {
+	habit := models.Habit{Name: "Swim", StartDate: "2024-01-01", Weekdays: []int{1, 3, 5}}
+	tasks := append(history(30, monWedFri, always), models.Task{Date: today.Format(schedule.DateLayout), Completed: true})
+
+	result := Forecast(habit, tasks, today, 3, 0, 0)
+	want := []string{"2024-05-17", "2024-05-20", "2024-05-22"}
+	if len(result.Days) != len(want) {
+		t.Fatalf("Days = %+v, want %v", result.Days, want)
+	}
+	for i, day := range result.Days {
+		if day.Date != want[i] {
+			t.Errorf("Days[%d] = %s, want %s", i, day.Date, want[i])
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"habit-tracker/server/account"
+	"habit-tracker/server/points"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxArchiveBytes caps the size of an account archive being restored
+const maxArchiveBytes = 50 << 20
+
+// ExportAccount downloads everything a user owns as a versioned JSON archive,
+// without secrets
+func ExportAccount(c *gin.Context) {
+	userID, err := validateAndGetUserID(c)
+	if err != nil {
+		return
+	}
+
+	user, err := fetchUserByID(c, userID)
+	if err != nil {
+		return
+	}
+
+	archive, err := account.Export(user)
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="account-%s.json"`, userID.Hex()))
+	c.JSON(http.StatusOK, archive)
+}
+
+// ImportAccount restores an account archive into a user that owns no records
+// yet. Records get new IDs; the user's name, email and password are kept.
+func ImportAccount(c *gin.Context) {
+	userID, err := validateAndGetUserID(c)
+	if err != nil {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxArchiveBytes)
+	var archive account.Archive
+	if err := c.ShouldBindJSON(&archive); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			SendError(c, http.StatusRequestEntityTooLarge, "Archive must not exceed 50 MB", err)
+			return
+		}
+		SendBadRequest(c, "Invalid request body", err)
+		return
+	}
+	if err := account.Check(archive); err != nil {
+		SendBadRequest(c, err.Error(), err)
+		return
+	}
+	if err := validateUserSettings(c, archive.Profile.Settings); err != nil {
+		return
+	}
+
+	if err := validateUserExists(c, userID); err != nil {
+		return
+	}
+
+	summary, err := account.Restore(userID, archive)
+	if err != nil {
+		if errors.Is(err, account.ErrNotEmpty) {
+			SendError(c, http.StatusConflict, "Archives can only be restored into an account without data", err)
+			return
+		}
+		SendInternalError(c, err)
+		return
+	}
+
+	if history, err := points.LoadHistory(userID); err != nil {
+		log.Printf("Error loading history for user %s: %v", userID.Hex(), err)
+	} else {
+		evaluateAchievements(userID, history)
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
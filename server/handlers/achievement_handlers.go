@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"habit-tracker/server/achievements"
+	"habit-tracker/server/models"
+	"habit-tracker/server/notify"
+	"habit-tracker/server/points"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// achievementStatus is an achievement definition with the user's unlock state
+type achievementStatus struct {
+	achievements.Rule
+	Unlocked   bool       `json:"unlocked"`
+	UnlockedAt *time.Time `json:"unlocked_at,omitempty"`
+}
+
+// GetUserAchievements returns every achievement with whether and when the user unlocked it
+func GetUserAchievements(c *gin.Context) {
+	userID, err := validateAndGetUserID(c)
+	if err != nil {
+		return
+	}
+
+	if err := validateUserExists(c, userID); err != nil {
+		return
+	}
+
+	unlocked, err := achievements.Unlocked(userID)
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+
+	statuses := make([]achievementStatus, 0, len(achievements.Rules))
+	for _, rule := range achievements.Rules {
+		status := achievementStatus{Rule: rule}
+		if achievement, ok := unlocked[rule.Key]; ok {
+			status.Unlocked = true
+			status.UnlockedAt = &achievement.UnlockedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	c.JSON(http.StatusOK, statuses)
+}
+
+// evaluateAchievements runs the achievement rules against a user's history
+// after a task event. Failures are logged rather than failing the task request
+// that triggered them.
+func evaluateAchievements(userID primitive.ObjectID, history points.History) []models.Achievement {
+	unlocked, err := achievements.Evaluate(userID, history.Tasks)
+	if err != nil {
+		log.Printf("Error evaluating achievements for user %s: %v", userID.Hex(), err)
+		return nil
+	}
+	for _, achievement := range unlocked {
+		log.Printf("User %s unlocked achievement %s", userID.Hex(), achievement.Key)
+		notifyAchievement(achievement)
+		publishEvent(userID, models.EventAchievementUnlocked, achievement)
+	}
+	return unlocked
+}
+
+// notifyAchievement tells the user about a newly unlocked achievement
+func notifyAchievement(achievement models.Achievement) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	title := achievement.Key
+	body := "Achievement unlocked"
+	for _, rule := range achievements.Rules {
+		if rule.Key == achievement.Key {
+			title = rule.Name
+			body = rule.Description
+		}
+	}
+
+	err := notify.Default.Notify(ctx, models.Notification{
+		UserID:    achievement.UserID,
+		Kind:      models.NotificationAchievement,
+		Title:     title,
+		Body:      body,
+		Data:      map[string]string{"achievement": achievement.Key},
+		CreatedAt: achievement.UnlockedAt,
+	})
+	if err != nil {
+		log.Printf("Error notifying user %s of achievement %s: %v", achievement.UserID.Hex(), achievement.Key, err)
+	}
+}
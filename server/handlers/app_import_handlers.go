@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"habit-tracker/server/importers"
+	"habit-tracker/server/schedule"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxAppExportBytes caps the size of an export from another app
+const maxAppExportBytes = 20 << 20
+
+// ImportFromApp imports the habits and history of another app's export, sent
+// as the request body or as the "file" field of a multipart form. The source
+// is "loop" (Loop Habit Tracker's CSV zip or Checkmarks.csv) or "habitica"
+// (Habitica's JSON data export). With preview=true it only reports what would
+// be imported.
+func ImportFromApp(c *gin.Context) {
+	source := c.Param("source")
+	if source != importers.SourceLoop && source != importers.SourceHabitica {
+		SendNotFound(c, "Unknown import source")
+		return
+	}
+
+	userID, err := requireUserIDQuery(c)
+	if err != nil {
+		return
+	}
+	preview := c.Query("preview") == "true"
+
+	user, err := fetchUserByID(c, userID)
+	if err != nil {
+		return
+	}
+
+	body, err := importBody(c, maxAppExportBytes)
+	if err != nil {
+		return
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			SendError(c, http.StatusRequestEntityTooLarge, "File must not exceed 20 MB", err)
+			return
+		}
+		SendBadRequest(c, "Could not read the file", err)
+		return
+	}
+
+	var plan importers.Plan
+	if source == importers.SourceLoop {
+		plan, err = importers.ParseLoop(data)
+	} else {
+		plan, err = importers.ParseHabitica(data, schedule.Location(user.Settings))
+	}
+	if err != nil {
+		SendBadRequest(c, err.Error(), err)
+		return
+	}
+
+	if preview {
+		c.JSON(http.StatusOK, plan.Preview())
+		return
+	}
+
+	result, err := importers.Apply(userID, plan)
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+
+	afterTasksImported(userID)
+
+	c.JSON(http.StatusOK, result)
+}
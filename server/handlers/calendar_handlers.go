@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"habit-tracker/server/calendar"
+	"habit-tracker/server/db"
+	"habit-tracker/server/models"
+	"habit-tracker/server/schedule"
+	"habit-tracker/server/webhooks"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// calendarHistoryDays is how far back the calendar feed lists tasks
+const calendarHistoryDays = 60
+
+// CreateCalendarFeed gives a user a calendar feed URL, replacing the previous
+// one if any, which stops working
+func CreateCalendarFeed(c *gin.Context) {
+	userID, err := validateAndGetUserID(c)
+	if err != nil {
+		return
+	}
+
+	token, err := webhooks.NewSecret()
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+
+	update := bson.M{"$set": bson.M{"calendar_token": token}}
+	if _, err := performUserUpdate(c, userID, update); err != nil {
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"token": token,
+		"url":   calendarFeedURL(c, token),
+	})
+}
+
+// DeleteCalendarFeed turns a user's calendar feed off
+func DeleteCalendarFeed(c *gin.Context) {
+	userID, err := validateAndGetUserID(c)
+	if err != nil {
+		return
+	}
+
+	update := bson.M{"$unset": bson.M{"calendar_token": ""}}
+	if _, err := performUserUpdate(c, userID, update); err != nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Calendar feed disabled"})
+}
+
+// GetCalendarFeed serves the iCalendar feed of the user owning the token: their
+// active habits as recurring events and their recent and upcoming tasks as
+// to-dos
+func GetCalendarFeed(c *gin.Context) {
+	token := strings.TrimSuffix(c.Param("token"), ".ics")
+	if token == "" {
+		SendNotFound(c, "Calendar feed not found")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var user models.User
+	err := db.UserColl.FindOne(ctx, bson.M{"calendar_token": token}).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			SendNotFound(c, "Calendar feed not found")
+			return
+		}
+		SendInternalError(c, err)
+		return
+	}
+
+	cursor, err := db.HabitColl.Find(ctx, bson.M{
+		"user_id":    user.ID,
+		"status":     models.HabitStatusActive,
+		"deleted_at": nil,
+	})
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	var habits []models.Habit
+	if err := cursor.All(ctx, &habits); err != nil {
+		SendInternalError(c, err)
+		return
+	}
+
+	loc := schedule.Location(user.Settings)
+	now := time.Now().In(loc)
+	since := now.AddDate(0, 0, -calendarHistoryDays).Format(dateLayout)
+	tasks, err := fetchTasksWithFilter(c, bson.M{"user_id": user.ID, "date": bson.M{"$gte": since}})
+	if err != nil {
+		return
+	}
+
+	c.Header("Content-Disposition", `inline; filename="habits.ics"`)
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(calendar.Feed(user, habits, tasks, loc, now)))
+}
+
+// calendarFeedURL returns the absolute URL of a calendar feed
+func calendarFeedURL(c *gin.Context, token string) string {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/api/calendar/feed/%s.ics", scheme, c.Request.Host, token)
+}
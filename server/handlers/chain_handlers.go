@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"time"
+
+	"habit-tracker/server/db"
+	"habit-tracker/server/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// chainStep is one habit of a chain together with its task for the day
+type chainStep struct {
+	Habit    models.Habit `json:"habit"`
+	Task     *models.Task `json:"task"`
+	Unlocked bool         `json:"unlocked"`
+}
+
+// dayChain is a chain of stacked habits in execution order
+type dayChain struct {
+	RootHabitID string      `json:"root_habit_id"`
+	Steps       []chainStep `json:"steps"`
+}
+
+// linkStats describes how often a habit follows its predecessor
+type linkStats struct {
+	PredecessorID            string  `json:"predecessor_id"`
+	HabitID                  string  `json:"habit_id"`
+	PredecessorCompletedDays int     `json:"predecessor_completed_days"`
+	FollowedThroughDays      int     `json:"followed_through_days"`
+	FollowThroughRate        float64 `json:"follow_through_rate"`
+}
+
+// chainStats describes how often a whole chain is completed
+type chainStats struct {
+	RootHabitID    string      `json:"root_habit_id"`
+	HabitIDs       []string    `json:"habit_ids"`
+	ActiveDays     int         `json:"active_days"`
+	CompletedDays  int         `json:"completed_days"`
+	CompletionRate float64     `json:"completion_rate"`
+	Links          []linkStats `json:"links"`
+}
+
+// GetDayChains returns a user's tasks for a day ordered by their habit chains.
+// Tasks that are not linked to a habit are listed separately.
+func GetDayChains(c *gin.Context) {
+	userID, err := requireUserIDQuery(c)
+	if err != nil {
+		return
+	}
+
+	day, err := parseDateParam(c, "date")
+	if err != nil {
+		return
+	}
+
+	habits, err := fetchBuildHabits(c, userID)
+	if err != nil {
+		return
+	}
+
+	tasks, err := fetchTasksWithFilter(c, bson.M{"user_id": userID, "date": dayFilter(day)})
+	if err != nil {
+		return
+	}
+
+	tasksByHabit := make(map[primitive.ObjectID]*models.Task)
+	unchained := []models.Task{}
+	for i := range tasks {
+		if tasks[i].HabitID == nil {
+			unchained = append(unchained, tasks[i])
+			continue
+		}
+		if _, ok := tasksByHabit[*tasks[i].HabitID]; !ok {
+			tasksByHabit[*tasks[i].HabitID] = &tasks[i]
+		}
+	}
+
+	chains := []dayChain{}
+	for _, chain := range buildHabitChains(habits) {
+		steps := make([]chainStep, 0, len(chain))
+		hasTask := false
+		for _, habit := range chain {
+			task := tasksByHabit[habit.ID]
+			if task != nil {
+				hasTask = true
+			}
+			steps = append(steps, chainStep{
+				Habit:    habit,
+				Task:     task,
+				Unlocked: isHabitUnlocked(habit, tasksByHabit),
+			})
+		}
+		if hasTask {
+			chains = append(chains, dayChain{RootHabitID: chain[0].ID.Hex(), Steps: steps})
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"date":      day.Format(dateLayout),
+		"chains":    chains,
+		"unchained": unchained,
+	})
+}
+
+// GetNextHabits reports the habits stacked on a task's habit and whether
+// completing the task has unlocked them for the same day
+func GetNextHabits(c *gin.Context) {
+	taskID, err := validateAndGetTaskID(c)
+	if err != nil {
+		return
+	}
+
+	task, err := fetchTaskByID(c, taskID)
+	if err != nil {
+		return
+	}
+	if task.HabitID == nil {
+		c.JSON(http.StatusOK, gin.H{"task_id": task.ID.Hex(), "completed": task.Completed, "next": []chainStep{}})
+		return
+	}
+
+	day, err := time.ParseInLocation(dateLayout, taskDay(task.Date), time.Local)
+	if err != nil {
+		SendBadRequest(c, "Task has an invalid date", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := db.HabitColl.Find(ctx, bson.M{"predecessor_id": *task.HabitID, "deleted_at": nil}, findOptions)
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var successors []models.Habit
+	if err = cursor.All(ctx, &successors); err != nil {
+		SendInternalError(c, err)
+		return
+	}
+
+	next := make([]chainStep, 0, len(successors))
+	for _, habit := range successors {
+		var successorTask *models.Task
+		var found models.Task
+		filter := bson.M{"habit_id": habit.ID, "date": dayFilter(day), "deleted_at": nil}
+		err := db.TaskColl.FindOne(ctx, filter).Decode(&found)
+		switch err {
+		case nil:
+			successorTask = &found
+		case mongo.ErrNoDocuments:
+		default:
+			SendInternalError(c, err)
+			return
+		}
+		next = append(next, chainStep{Habit: habit, Task: successorTask, Unlocked: task.Completed})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"task_id":   task.ID.Hex(),
+		"completed": task.Completed,
+		"next":      next,
+	})
+}
+
+// GetChainStats returns completion rates of a user's habit chains over a date range
+func GetChainStats(c *gin.Context) {
+	userID, err := requireUserIDQuery(c)
+	if err != nil {
+		return
+	}
+
+	start, end, err := parseDateRange(c, 30, 366)
+	if err != nil {
+		return
+	}
+
+	habits, err := fetchBuildHabits(c, userID)
+	if err != nil {
+		return
+	}
+
+	tasks, err := fetchTasksWithFilter(c, bson.M{
+		"user_id":  userID,
+		"habit_id": bson.M{"$ne": nil},
+		"date":     dateRangeFilter(start, end),
+	})
+	if err != nil {
+		return
+	}
+
+	// completion[day][habitID] is true when the habit's task was completed that day
+	completion := make(map[string]map[primitive.ObjectID]bool)
+	for _, task := range tasks {
+		day := taskDay(task.Date)
+		if completion[day] == nil {
+			completion[day] = make(map[primitive.ObjectID]bool)
+		}
+		completion[day][*task.HabitID] = completion[day][*task.HabitID] || task.Completed
+	}
+
+	stats := []chainStats{}
+	for _, chain := range buildHabitChains(habits) {
+		if len(chain) < 2 {
+			continue
+		}
+		stats = append(stats, calculateChainStats(chain, completion))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id":    userID.Hex(),
+		"start_date": start.Format(dateLayout),
+		"end_date":   end.Format(dateLayout),
+		"chains":     stats,
+	})
+}
+
+// calculateChainStats computes how often a chain was completed end to end and
+// how often each habit followed its predecessor
+func calculateChainStats(chain []models.Habit, completion map[string]map[primitive.ObjectID]bool) chainStats {
+	stats := chainStats{RootHabitID: chain[0].ID.Hex(), Links: []linkStats{}}
+	for _, habit := range chain {
+		stats.HabitIDs = append(stats.HabitIDs, habit.ID.Hex())
+	}
+
+	for _, habitsDone := range completion {
+		active := false
+		allDone := true
+		for _, habit := range chain {
+			done, scheduled := habitsDone[habit.ID]
+			active = active || scheduled
+			allDone = allDone && scheduled && done
+		}
+		if active {
+			stats.ActiveDays++
+			if allDone {
+				stats.CompletedDays++
+			}
+		}
+	}
+	stats.CompletionRate = rate(stats.CompletedDays, stats.ActiveDays)
+
+	for _, habit := range chain[1:] {
+		link := linkStats{PredecessorID: habit.PredecessorID.Hex(), HabitID: habit.ID.Hex()}
+		for _, habitsDone := range completion {
+			if habitsDone[*habit.PredecessorID] {
+				link.PredecessorCompletedDays++
+				if habitsDone[habit.ID] {
+					link.FollowedThroughDays++
+				}
+			}
+		}
+		link.FollowThroughRate = rate(link.FollowedThroughDays, link.PredecessorCompletedDays)
+		stats.Links = append(stats.Links, link)
+	}
+
+	return stats
+}
+
+// rate returns part/total rounded to four decimal places, or 0 when total is 0
+func rate(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return roundTo(float64(part)/float64(total), 4)
+}
+
+// fetchBuildHabits retrieves a user's build habits in creation order
+func fetchBuildHabits(c *gin.Context, userID primitive.ObjectID) ([]models.Habit, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"user_id": userID, "type": models.HabitTypeBuild, "deleted_at": nil}
+	findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := db.HabitColl.Find(ctx, filter, findOptions)
+	if err != nil {
+		SendInternalError(c, err)
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var habits []models.Habit
+	if err = cursor.All(ctx, &habits); err != nil {
+		SendInternalError(c, err)
+		return nil, err
+	}
+	return habits, nil
+}
+
+// buildHabitChains groups habits into chains rooted at habits without a
+// predecessor. Each chain lists its habits depth-first, so every habit comes
+// right after the habit it is stacked on; siblings keep creation order.
+func buildHabitChains(habits []models.Habit) [][]models.Habit {
+	byID := make(map[primitive.ObjectID]models.Habit, len(habits))
+	for _, habit := range habits {
+		byID[habit.ID] = habit
+	}
+
+	successors := make(map[primitive.ObjectID][]models.Habit)
+	var roots []models.Habit
+	for _, habit := range habits {
+		if habit.PredecessorID != nil {
+			if _, ok := byID[*habit.PredecessorID]; ok {
+				successors[*habit.PredecessorID] = append(successors[*habit.PredecessorID], habit)
+				continue
+			}
+		}
+		roots = append(roots, habit)
+	}
+	sort.SliceStable(roots, func(i, j int) bool { return roots[i].CreatedAt.Before(roots[j].CreatedAt) })
+
+	visited := make(map[primitive.ObjectID]bool, len(habits))
+	var walk func(habit models.Habit, chain []models.Habit) []models.Habit
+	walk = func(habit models.Habit, chain []models.Habit) []models.Habit {
+		if visited[habit.ID] {
+			return chain
+		}
+		visited[habit.ID] = true
+		chain = append(chain, habit)
+		for _, next := range successors[habit.ID] {
+			chain = walk(next, chain)
+		}
+		return chain
+	}
+
+	chains := make([][]models.Habit, 0, len(roots))
+	for _, root := range roots {
+		chains = append(chains, walk(root, nil))
+	}
+	return chains
+}
+
+// isHabitUnlocked reports whether a habit can be done today: it has no
+// predecessor, its predecessor has no task today, or that task is completed
+func isHabitUnlocked(habit models.Habit, tasksByHabit map[primitive.ObjectID]*models.Task) bool {
+	if habit.PredecessorID == nil {
+		return true
+	}
+	predecessorTask, ok := tasksByHabit[*habit.PredecessorID]
+	return !ok || predecessorTask.Completed
+}
@@ -0,0 +1,356 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"habit-tracker/server/db"
+	"habit-tracker/server/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// normalizeChecklistItems assigns IDs to checklist items submitted with a new task
+func normalizeChecklistItems(c *gin.Context, items []models.ChecklistItem) ([]models.ChecklistItem, error) {
+	normalized := make([]models.ChecklistItem, 0, len(items))
+	for _, item := range items {
+		name := strings.TrimSpace(item.Name)
+		if name == "" {
+			SendBadRequest(c, "Checklist item name is required", nil)
+			return nil, fmt.Errorf("checklist item name is required")
+		}
+		normalized = append(normalized, models.ChecklistItem{
+			ID:        primitive.NewObjectID(),
+			Name:      name,
+			Completed: item.Completed,
+		})
+	}
+	return normalized, nil
+}
+
+// allItemsCompleted reports whether every checklist item is done
+func allItemsCompleted(items []models.ChecklistItem) bool {
+	for _, item := range items {
+		if !item.Completed {
+			return false
+		}
+	}
+	return true
+}
+
+// AddChecklistItem appends an item to a task's checklist, or inserts it at
+// the given position
+func AddChecklistItem(c *gin.Context) {
+	taskID, err := validateAndGetTaskID(c)
+	if err != nil {
+		return
+	}
+
+	var body struct {
+		Name     string `json:"name"`
+		Position *int   `json:"position"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		SendBadRequest(c, "Invalid request body", err)
+		return
+	}
+
+	name := strings.TrimSpace(body.Name)
+	if name == "" {
+		SendBadRequest(c, "Checklist item name is required", nil)
+		return
+	}
+
+	push := bson.M{"$each": bson.A{models.ChecklistItem{
+		ID:   primitive.NewObjectID(),
+		Name: name,
+	}}}
+	if body.Position != nil {
+		if *body.Position < 0 {
+			SendBadRequest(c, "Position must not be negative", nil)
+			return
+		}
+		push["$position"] = *body.Position
+	}
+
+	if err := applyChecklistUpdate(c, bson.M{"_id": taskID}, bson.M{"$push": bson.M{"items": push}}); err != nil {
+		return
+	}
+
+	respondWithSyncedTask(c, taskID)
+}
+
+// UpdateChecklistItem renames or toggles a single checklist item
+func UpdateChecklistItem(c *gin.Context) {
+	taskID, err := validateAndGetTaskID(c)
+	if err != nil {
+		return
+	}
+
+	itemID, err := validateAndGetItemID(c)
+	if err != nil {
+		return
+	}
+
+	var body struct {
+		Name      *string `json:"name"`
+		Completed *bool   `json:"completed"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		SendBadRequest(c, "Invalid request body", err)
+		return
+	}
+
+	updateFields := bson.M{}
+	if body.Name != nil {
+		name := strings.TrimSpace(*body.Name)
+		if name == "" {
+			SendBadRequest(c, "Checklist item name is required", nil)
+			return
+		}
+		updateFields["items.$.name"] = name
+	}
+	if body.Completed != nil {
+		updateFields["items.$.completed"] = *body.Completed
+	}
+	if len(updateFields) == 0 {
+		SendBadRequest(c, "No valid fields to update", nil)
+		return
+	}
+
+	filter := bson.M{"_id": taskID, "items._id": itemID}
+	if err := applyChecklistUpdate(c, filter, bson.M{"$set": updateFields}); err != nil {
+		return
+	}
+
+	respondWithSyncedTask(c, taskID)
+}
+
+// DeleteChecklistItem removes an item from a task's checklist
+func DeleteChecklistItem(c *gin.Context) {
+	taskID, err := validateAndGetTaskID(c)
+	if err != nil {
+		return
+	}
+
+	itemID, err := validateAndGetItemID(c)
+	if err != nil {
+		return
+	}
+
+	filter := bson.M{"_id": taskID, "items._id": itemID}
+	update := bson.M{"$pull": bson.M{"items": bson.M{"_id": itemID}}}
+	if err := applyChecklistUpdate(c, filter, update); err != nil {
+		return
+	}
+
+	respondWithSyncedTask(c, taskID)
+}
+
+// ReorderChecklistItems rewrites the order of a task's checklist. The body must
+// list every existing item ID exactly once.
+func ReorderChecklistItems(c *gin.Context) {
+	taskID, err := validateAndGetTaskID(c)
+	if err != nil {
+		return
+	}
+
+	var body struct {
+		ItemIDs []string `json:"item_ids"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		SendBadRequest(c, "Invalid request body", err)
+		return
+	}
+
+	task, err := fetchTaskByID(c, taskID)
+	if err != nil {
+		return
+	}
+
+	reordered, err := reorderItems(c, task.Items, body.ItemIDs)
+	if err != nil {
+		return
+	}
+	if len(reordered) == 0 {
+		c.JSON(http.StatusOK, task)
+		return
+	}
+
+	// Only apply the new order if the checklist still holds the same items
+	itemIDs := make(bson.A, 0, len(reordered))
+	for _, item := range reordered {
+		itemIDs = append(itemIDs, item.ID)
+	}
+	filter := bson.M{
+		"_id":        taskID,
+		"deleted_at": nil,
+		"items":      bson.M{"$size": len(reordered)},
+		"items._id":  bson.M{"$all": itemIDs},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := db.TaskColl.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"items": reordered}})
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	if result.MatchedCount == 0 {
+		SendError(c, http.StatusConflict, "Checklist changed while reordering, please retry", nil)
+		return
+	}
+
+	respondWithSyncedTask(c, taskID)
+}
+
+// reorderItems arranges items in the order of the given IDs
+func reorderItems(c *gin.Context, items []models.ChecklistItem, ids []string) ([]models.ChecklistItem, error) {
+	if len(ids) != len(items) {
+		SendBadRequest(c, "item_ids must list every checklist item exactly once", nil)
+		return nil, fmt.Errorf("expected %d item IDs, got %d", len(items), len(ids))
+	}
+
+	byID := make(map[primitive.ObjectID]models.ChecklistItem, len(items))
+	for _, item := range items {
+		byID[item.ID] = item
+	}
+
+	reordered := make([]models.ChecklistItem, 0, len(ids))
+	for _, id := range ids {
+		objectID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			SendBadRequest(c, "Invalid checklist item ID", err)
+			return nil, err
+		}
+		item, ok := byID[objectID]
+		if !ok {
+			SendBadRequest(c, "item_ids must list every checklist item exactly once", nil)
+			return nil, fmt.Errorf("unknown or duplicate item ID: %s", id)
+		}
+		delete(byID, objectID)
+		reordered = append(reordered, item)
+	}
+
+	return reordered, nil
+}
+
+// validateAndGetItemID validates the checklist item ID from the request
+func validateAndGetItemID(c *gin.Context) (primitive.ObjectID, error) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("itemId"))
+	if err != nil {
+		SendBadRequest(c, "Invalid checklist item ID", err)
+		return primitive.NilObjectID, err
+	}
+	return objectID, nil
+}
+
+// fetchTaskByID retrieves a task by its ID
+func fetchTaskByID(c *gin.Context, taskID primitive.ObjectID) (models.Task, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var task models.Task
+	err := db.TaskColl.FindOne(ctx, bson.M{"_id": taskID, "deleted_at": nil}).Decode(&task)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			SendNotFound(c, "Task not found")
+			return models.Task{}, err
+		}
+		SendInternalError(c, err)
+		return models.Task{}, err
+	}
+	return task, nil
+}
+
+// applyChecklistUpdate runs an update against a task's checklist
+func applyChecklistUpdate(c *gin.Context, filter bson.M, update bson.M) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter["deleted_at"] = nil
+
+	result, err := db.TaskColl.UpdateOne(ctx, filter, update)
+	if err != nil {
+		SendInternalError(c, err)
+		return err
+	}
+	if result.MatchedCount == 0 {
+		if _, ok := filter["items._id"]; ok {
+			SendNotFound(c, "Task or checklist item not found")
+		} else {
+			SendNotFound(c, "Task not found")
+		}
+		return fmt.Errorf("task not found")
+	}
+	return nil
+}
+
+// respondWithSyncedTask syncs the parent completion flag and returns the task
+func respondWithSyncedTask(c *gin.Context, taskID primitive.ObjectID) {
+	previous, task, err := syncTaskCompletion(c, taskID)
+	if err != nil {
+		return
+	}
+
+	afterTaskChange(&previous, task)
+
+	c.JSON(http.StatusOK, task)
+}
+
+// syncTaskCompletion marks a task completed exactly when all of its checklist
+// items are done. Tasks without items keep their own completion flag. It
+// returns the task before and after the sync.
+func syncTaskCompletion(c *gin.Context, taskID primitive.ObjectID) (models.Task, models.Task, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	items := bson.M{"$ifNull": bson.A{"$items", bson.A{}}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"completed": bson.M{"$cond": bson.A{
+				bson.M{"$gt": bson.A{bson.M{"$size": items}, 0}},
+				bson.M{"$allElementsTrue": bson.A{"$items.completed"}},
+				"$completed",
+			}},
+			"updated_at": "$$NOW",
+			"revision":   bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$revision", 0}}, 1}},
+		}}},
+	}
+
+	var previous models.Task
+	err := db.TaskColl.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": taskID},
+		pipeline,
+		options.FindOneAndUpdate().SetReturnDocument(options.Before),
+	).Decode(&previous)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			SendNotFound(c, "Task not found")
+			return models.Task{}, models.Task{}, err
+		}
+		SendInternalError(c, err)
+		return models.Task{}, models.Task{}, err
+	}
+
+	// Re-read the task so updated_at and revision are as stored
+	var task models.Task
+	if err := db.TaskColl.FindOne(ctx, bson.M{"_id": taskID}).Decode(&task); err != nil {
+		if err == mongo.ErrNoDocuments {
+			SendNotFound(c, "Task not found")
+			return models.Task{}, models.Task{}, err
+		}
+		SendInternalError(c, err)
+		return models.Task{}, models.Task{}, err
+	}
+	return previous, task, nil
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// dateLayout is the YYYY-MM-DD format used for task and habit dates
+const dateLayout = "2006-01-02"
+
+// daysBetween returns the number of calendar days from start to end
+func daysBetween(start, end time.Time) int {
+	startUTC := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	endUTC := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, time.UTC)
+	return int(endUTC.Sub(startUTC).Hours() / 24)
+}
+
+// taskDay returns the YYYY-MM-DD part of a task date, which may be stored
+// either as a plain date or as an RFC3339 timestamp
+func taskDay(date string) string {
+	if len(date) >= len(dateLayout) {
+		return date[:len(dateLayout)]
+	}
+	return date
+}
+
+// dayFilter matches task dates falling on the given day
+func dayFilter(day time.Time) bson.M {
+	return dateRangeFilter(day, day)
+}
+
+// dateRangeFilter matches task dates falling between start and end inclusive
+func dateRangeFilter(start, end time.Time) bson.M {
+	return bson.M{
+		"$gte": start.Format(dateLayout),
+		"$lt":  end.AddDate(0, 0, 1).Format(dateLayout),
+	}
+}
+
+// parseDateParam parses a YYYY-MM-DD query parameter, defaulting to today
+func parseDateParam(c *gin.Context, name string) (time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		now := time.Now()
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local), nil
+	}
+
+	date, err := time.ParseInLocation(dateLayout, value, time.Local)
+	if err != nil {
+		SendBadRequest(c, fmt.Sprintf("%s must be in YYYY-MM-DD format", name), err)
+		return time.Time{}, err
+	}
+	return date, nil
+}
+
+// parseDateRange reads the start_date and end_date query parameters. The end
+// defaults to today and the start to defaultDays before the end; ranges longer
+// than maxDays are rejected.
+func parseDateRange(c *gin.Context, defaultDays, maxDays int) (time.Time, time.Time, error) {
+	end, err := parseDateParam(c, "end_date")
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	start := end.AddDate(0, 0, -(defaultDays - 1))
+	if c.Query("start_date") != "" {
+		if start, err = parseDateParam(c, "start_date"); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+
+	if start.After(end) {
+		SendBadRequest(c, "start_date must not be after end_date", nil)
+		return time.Time{}, time.Time{}, fmt.Errorf("start date after end date")
+	}
+	if daysBetween(start, end)+1 > maxDays {
+		SendBadRequest(c, fmt.Sprintf("Date range must not exceed %d days", maxDays), nil)
+		return time.Time{}, time.Time{}, fmt.Errorf("date range too long")
+	}
+
+	return start, end, nil
+}
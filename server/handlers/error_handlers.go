@@ -1,11 +1,15 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
+// errInvalidPagination is returned when limit or offset query parameters are invalid
+var errInvalidPagination = errors.New("invalid pagination parameters")
+
 // ErrorResponse represents a standardized error response structure
 type ErrorResponse struct {
 	Status  int    `json:"status"`
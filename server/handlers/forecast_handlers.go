@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"habit-tracker/server/forecast"
+	"habit-tracker/server/models"
+	"habit-tracker/server/schedule"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GetForecast predicts how likely a user is to complete each active build
+// habit on upcoming days, and when optional streak and count targets are
+// likely reached
+func GetForecast(c *gin.Context) {
+	userID, err := requireUserIDQuery(c)
+	if err != nil {
+		return
+	}
+
+	days, err := positiveIntQuery(c, "days", 14, 90)
+	if err != nil {
+		return
+	}
+	streakTarget, err := positiveIntQuery(c, "streak", 0, forecast.Horizon)
+	if err != nil {
+		return
+	}
+	countTarget, err := positiveIntQuery(c, "count", 0, 100000)
+	if err != nil {
+		return
+	}
+
+	user, err := fetchUserByID(c, userID)
+	if err != nil {
+		return
+	}
+
+	habits, err := fetchBuildHabits(c, userID)
+	if err != nil {
+		return
+	}
+	if habitID := c.Query("habit_id"); habitID != "" {
+		objectID, err := primitive.ObjectIDFromHex(habitID)
+		if err != nil {
+			SendBadRequest(c, "Invalid habit ID", err)
+			return
+		}
+		habits = filterHabits(habits, func(habit models.Habit) bool { return habit.ID == objectID })
+		if len(habits) == 0 {
+			SendNotFound(c, "Habit not found")
+			return
+		}
+	}
+	habits = filterHabits(habits, func(habit models.Habit) bool { return habit.Status == models.HabitStatusActive })
+
+	tasks, err := fetchTasksWithFilter(c, bson.M{"user_id": userID, "habit_id": bson.M{"$ne": nil}})
+	if err != nil {
+		return
+	}
+	byHabit := make(map[primitive.ObjectID][]models.Task)
+	for _, task := range tasks {
+		byHabit[*task.HabitID] = append(byHabit[*task.HabitID], task)
+	}
+
+	now := time.Now().In(schedule.Location(user.Settings))
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	forecasts := []forecast.Habit{}
+	for _, habit := range habits {
+		forecasts = append(forecasts, forecast.Forecast(habit, byHabit[habit.ID], today, days, streakTarget, countTarget))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id": userID.Hex(),
+		"date":    today.Format(dateLayout),
+		"habits":  forecasts,
+	})
+}
+
+// positiveIntQuery parses an optional integer query parameter between 1 and max
+func positiveIntQuery(c *gin.Context, name string, defaultValue, max int) (int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 1 || number > max {
+		SendBadRequest(c, fmt.Sprintf("%s must be between 1 and %d", name, max), err)
+		if err == nil {
+			err = fmt.Errorf("%s out of range", name)
+		}
+		return 0, err
+	}
+	return number, nil
+}
+
+// filterHabits keeps the habits matching keep
+func filterHabits(habits []models.Habit, keep func(models.Habit) bool) []models.Habit {
+	kept := make([]models.Habit, 0, len(habits))
+	for _, habit := range habits {
+		if keep(habit) {
+			kept = append(kept, habit)
+		}
+	}
+	return kept
+}
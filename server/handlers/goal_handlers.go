@@ -0,0 +1,486 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"habit-tracker/server/db"
+	"habit-tracker/server/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// goalMilestone is a checkpoint on the way to a goal's target
+type goalMilestone struct {
+	Value     float64 `json:"value"`
+	ReachedOn string  `json:"reached_on,omitempty"`
+}
+
+// goalProgress summarizes how far a goal has come and where the current pace leads
+type goalProgress struct {
+	Current                 float64         `json:"current"`
+	Percent                 float64         `json:"percent"`
+	Remaining               float64         `json:"remaining"`
+	DaysElapsed             int             `json:"days_elapsed"`
+	DaysLeft                int             `json:"days_left"`
+	Pace                    float64         `json:"pace_per_day"`
+	RequiredPace            float64         `json:"required_pace_per_day"`
+	ProjectedCompletionDate string          `json:"projected_completion_date,omitempty"`
+	CompletedOn             string          `json:"completed_on,omitempty"`
+	OnTrack                 bool            `json:"on_track"`
+	Milestones              []goalMilestone `json:"milestones"`
+}
+
+// goalWithProgress is a goal together with its computed progress
+type goalWithProgress struct {
+	models.Goal
+	Progress goalProgress `json:"progress"`
+}
+
+// GetGoals returns a user's goals with their progress
+func GetGoals(c *gin.Context) {
+	userID, err := requireUserIDQuery(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "end_date", Value: 1}})
+	cursor, err := db.GoalColl.Find(ctx, bson.M{"user_id": userID}, findOptions)
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var goals []models.Goal
+	if err = cursor.All(ctx, &goals); err != nil {
+		SendInternalError(c, err)
+		return
+	}
+
+	results := make([]goalWithProgress, 0, len(goals))
+	for _, goal := range goals {
+		result, err := reviewGoal(c, goal)
+		if err != nil {
+			return
+		}
+		results = append(results, result)
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+// GetGoalById returns a goal with its progress, milestones and projection
+func GetGoalById(c *gin.Context) {
+	goalID, err := validateAndGetGoalID(c)
+	if err != nil {
+		return
+	}
+
+	goal, err := fetchGoalByID(c, goalID)
+	if err != nil {
+		return
+	}
+
+	result, err := reviewGoal(c, goal)
+	if err != nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// validateAndGetGoalID validates the goal ID from the request
+func validateAndGetGoalID(c *gin.Context) (primitive.ObjectID, error) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		SendBadRequest(c, "Invalid goal ID", err)
+		return primitive.NilObjectID, err
+	}
+	return objectID, nil
+}
+
+// fetchGoalByID retrieves a goal by its ID
+func fetchGoalByID(c *gin.Context, goalID primitive.ObjectID) (models.Goal, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var goal models.Goal
+	err := db.GoalColl.FindOne(ctx, bson.M{"_id": goalID}).Decode(&goal)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			SendNotFound(c, "Goal not found")
+			return models.Goal{}, err
+		}
+		SendInternalError(c, err)
+		return models.Goal{}, err
+	}
+	return goal, nil
+}
+
+// CreateGoal creates a new goal
+func CreateGoal(c *gin.Context) {
+	goal, err := parseAndValidateGoal(c)
+	if err != nil {
+		return
+	}
+
+	if err := validateUserExists(c, goal.UserID); err != nil {
+		return
+	}
+
+	for _, habitID := range goal.HabitIDs {
+		if err := validateTaskHabit(c, habitID, goal.UserID); err != nil {
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := db.GoalColl.InsertOne(ctx, goal)
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	goal.ID = result.InsertedID.(primitive.ObjectID)
+
+	review, err := reviewGoal(c, goal)
+	if err != nil {
+		return
+	}
+
+	c.JSON(http.StatusCreated, review)
+}
+
+// parseAndValidateGoal parses and validates the goal from the request body
+func parseAndValidateGoal(c *gin.Context) (models.Goal, error) {
+	var goal models.Goal
+	if err := c.ShouldBindJSON(&goal); err != nil {
+		SendBadRequest(c, "Invalid request body", err)
+		return models.Goal{}, err
+	}
+
+	goal.Name = strings.TrimSpace(goal.Name)
+	if goal.Name == "" {
+		SendBadRequest(c, "Goal name is required", nil)
+		return models.Goal{}, fmt.Errorf("goal name is required")
+	}
+
+	if len(goal.HabitIDs) == 0 {
+		SendBadRequest(c, "A goal needs at least one habit", nil)
+		return models.Goal{}, fmt.Errorf("goal has no habits")
+	}
+
+	if goal.Metric == "" {
+		goal.Metric = models.GoalMetricCount
+	}
+	if goal.Metric != models.GoalMetricCount && goal.Metric != models.GoalMetricAmount {
+		SendBadRequest(c, "Goal metric must be 'count' or 'amount'", nil)
+		return models.Goal{}, fmt.Errorf("invalid goal metric: %s", goal.Metric)
+	}
+
+	if goal.Target <= 0 {
+		SendBadRequest(c, "Goal target must be positive", nil)
+		return models.Goal{}, fmt.Errorf("goal target must be positive")
+	}
+
+	if goal.StartDate == "" {
+		goal.StartDate = time.Now().Format(dateLayout)
+	}
+	start, err := time.Parse(dateLayout, goal.StartDate)
+	if err != nil {
+		SendBadRequest(c, "Start date must be in YYYY-MM-DD format", err)
+		return models.Goal{}, err
+	}
+	end, err := time.Parse(dateLayout, goal.EndDate)
+	if err != nil {
+		SendBadRequest(c, "End date must be in YYYY-MM-DD format", err)
+		return models.Goal{}, err
+	}
+	if end.Before(start) {
+		SendBadRequest(c, "End date must not be before start date", nil)
+		return models.Goal{}, fmt.Errorf("end date before start date")
+	}
+
+	milestones, err := normalizeMilestones(c, goal.Milestones, goal.Target)
+	if err != nil {
+		return models.Goal{}, err
+	}
+	goal.Milestones = milestones
+	goal.CreatedAt = time.Now()
+
+	return goal, nil
+}
+
+// normalizeMilestones sorts the milestones and checks they lie within the
+// target. Without milestones the goal gets quarter checkpoints.
+func normalizeMilestones(c *gin.Context, milestones []float64, target float64) ([]float64, error) {
+	if len(milestones) == 0 {
+		return defaultMilestones(target), nil
+	}
+
+	normalized := append([]float64(nil), milestones...)
+	sort.Float64s(normalized)
+	for _, milestone := range normalized {
+		if milestone <= 0 || milestone > target {
+			SendBadRequest(c, "Milestones must be positive and not exceed the target", nil)
+			return nil, fmt.Errorf("invalid milestone: %v", milestone)
+		}
+	}
+	return normalized, nil
+}
+
+// defaultMilestones returns the quarter checkpoints of a target
+func defaultMilestones(target float64) []float64 {
+	return []float64{target * 0.25, target * 0.5, target * 0.75, target}
+}
+
+// UpdateGoal updates a goal's name, target, end date or milestones
+func UpdateGoal(c *gin.Context) {
+	goalID, err := validateAndGetGoalID(c)
+	if err != nil {
+		return
+	}
+
+	goal, err := fetchGoalByID(c, goalID)
+	if err != nil {
+		return
+	}
+
+	var updateData struct {
+		Name       *string    `json:"name"`
+		Target     *float64   `json:"target"`
+		EndDate    *string    `json:"end_date"`
+		Milestones *[]float64 `json:"milestones"`
+	}
+	if err := c.ShouldBindJSON(&updateData); err != nil {
+		SendBadRequest(c, "Invalid request body", err)
+		return
+	}
+
+	updateFields := bson.M{}
+	if updateData.Name != nil {
+		name := strings.TrimSpace(*updateData.Name)
+		if name == "" {
+			SendBadRequest(c, "Goal name is required", nil)
+			return
+		}
+		updateFields["name"] = name
+	}
+	previousTarget := goal.Target
+	if updateData.Target != nil {
+		if *updateData.Target <= 0 {
+			SendBadRequest(c, "Goal target must be positive", nil)
+			return
+		}
+		goal.Target = *updateData.Target
+		updateFields["target"] = goal.Target
+	}
+	if updateData.EndDate != nil {
+		if _, err := time.Parse(dateLayout, *updateData.EndDate); err != nil {
+			SendBadRequest(c, "End date must be in YYYY-MM-DD format", err)
+			return
+		}
+		if *updateData.EndDate < goal.StartDate {
+			SendBadRequest(c, "End date must not be before start date", nil)
+			return
+		}
+		updateFields["end_date"] = *updateData.EndDate
+	}
+	if updateData.Milestones != nil || updateData.Target != nil {
+		milestones := goal.Milestones
+		if updateData.Milestones != nil {
+			milestones = *updateData.Milestones
+		} else if slices.Equal(milestones, defaultMilestones(previousTarget)) {
+			// Default quarter milestones follow the new target; custom ones
+			// are kept and must still fit it
+			milestones = nil
+		}
+		normalized, err := normalizeMilestones(c, milestones, goal.Target)
+		if err != nil {
+			return
+		}
+		updateFields["milestones"] = normalized
+	}
+	if len(updateFields) == 0 {
+		SendBadRequest(c, "No valid fields to update", nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var updatedGoal models.Goal
+	err = db.GoalColl.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": goalID},
+		bson.M{"$set": updateFields},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updatedGoal)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			SendNotFound(c, "Goal not found")
+			return
+		}
+		SendInternalError(c, err)
+		return
+	}
+
+	review, err := reviewGoal(c, updatedGoal)
+	if err != nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, review)
+}
+
+// DeleteGoal deletes a goal. Task history is not affected.
+func DeleteGoal(c *gin.Context) {
+	goalID, err := validateAndGetGoalID(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := db.GoalColl.DeleteOne(ctx, bson.M{"_id": goalID})
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	if result.DeletedCount == 0 {
+		SendNotFound(c, "Goal not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Goal deleted successfully"})
+}
+
+// reviewGoal loads the completed tasks counting towards a goal and computes its progress
+func reviewGoal(c *gin.Context, goal models.Goal) (goalWithProgress, error) {
+	start, err := time.ParseInLocation(dateLayout, goal.StartDate, time.Local)
+	if err != nil {
+		SendInternalError(c, err)
+		return goalWithProgress{}, err
+	}
+	end, err := time.ParseInLocation(dateLayout, goal.EndDate, time.Local)
+	if err != nil {
+		SendInternalError(c, err)
+		return goalWithProgress{}, err
+	}
+
+	tasks, err := fetchTasksWithFilter(c, bson.M{
+		"user_id":   goal.UserID,
+		"habit_id":  bson.M{"$in": goal.HabitIDs},
+		"completed": true,
+		"date":      dateRangeFilter(start, end),
+	})
+	if err != nil {
+		return goalWithProgress{}, err
+	}
+
+	return goalWithProgress{
+		Goal:     goal,
+		Progress: calculateGoalProgress(goal, tasks, time.Now()),
+	}, nil
+}
+
+// calculateGoalProgress adds up the completed tasks of a goal day by day. The
+// pace is the average progress per elapsed day since the start; the projected
+// completion date assumes that pace continues from today.
+func calculateGoalProgress(goal models.Goal, tasks []models.Task, now time.Time) goalProgress {
+	perDay := make(map[string]float64)
+	for _, task := range tasks {
+		value := 1.0
+		if goal.Metric == models.GoalMetricAmount {
+			if task.Amount == nil {
+				continue
+			}
+			value = *task.Amount
+		}
+		perDay[taskDay(task.Date)] += value
+	}
+
+	days := make([]string, 0, len(perDay))
+	for day := range perDay {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+
+	progress := goalProgress{Milestones: make([]goalMilestone, 0, len(goal.Milestones))}
+	for _, milestone := range goal.Milestones {
+		progress.Milestones = append(progress.Milestones, goalMilestone{Value: milestone})
+	}
+
+	// Walk the days in order to date each milestone and the goal itself
+	for _, day := range days {
+		progress.Current += perDay[day]
+		for i := range progress.Milestones {
+			if progress.Milestones[i].ReachedOn == "" && progress.Current >= progress.Milestones[i].Value {
+				progress.Milestones[i].ReachedOn = day
+			}
+		}
+		if progress.CompletedOn == "" && progress.Current >= goal.Target {
+			progress.CompletedOn = day
+		}
+	}
+
+	progress.Current = roundTo(progress.Current, 2)
+	progress.Percent = roundTo(math.Min(progress.Current/goal.Target, 1)*100, 2)
+	progress.Remaining = roundTo(math.Max(goal.Target-progress.Current, 0), 2)
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	start, _ := time.ParseInLocation(dateLayout, goal.StartDate, now.Location())
+	end, _ := time.ParseInLocation(dateLayout, goal.EndDate, now.Location())
+
+	if !today.Before(start) {
+		last := today
+		if last.After(end) {
+			last = end
+		}
+		progress.DaysElapsed = daysBetween(start, last) + 1
+		progress.Pace = roundTo(progress.Current/float64(progress.DaysElapsed), 2)
+	}
+	if !today.After(end) {
+		progress.DaysLeft = daysBetween(today, end) + 1
+		if progress.DaysLeft > daysBetween(start, end)+1 {
+			progress.DaysLeft = daysBetween(start, end) + 1
+		}
+		progress.RequiredPace = roundTo(progress.Remaining/float64(progress.DaysLeft), 2)
+	}
+
+	switch {
+	case progress.CompletedOn != "":
+		progress.OnTrack = true
+	case today.Before(start):
+		progress.OnTrack = true
+	case progress.Current > 0 && !today.After(end):
+		daysNeeded := int(math.Ceil(progress.Remaining / (progress.Current / float64(progress.DaysElapsed))))
+		projected := today.AddDate(0, 0, daysNeeded)
+		progress.ProjectedCompletionDate = projected.Format(dateLayout)
+		progress.OnTrack = !projected.After(end)
+	}
+
+	return progress
+}
+
+// roundTo rounds value to the given number of decimal places
+func roundTo(value float64, places int) float64 {
+	factor := math.Pow(10, float64(places))
+	return math.Round(value*factor) / factor
+}
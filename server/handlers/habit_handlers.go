@@ -0,0 +1,871 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"habit-tracker/server/db"
+	"habit-tracker/server/models"
+	"habit-tracker/server/schedule"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetHabits returns habits, optionally filtered by user_id, type and status
+func GetHabits(c *gin.Context) {
+	filter := bson.M{"deleted_at": nil}
+
+	if userID := c.Query("user_id"); userID != "" {
+		objectID, err := primitive.ObjectIDFromHex(userID)
+		if err != nil {
+			SendBadRequest(c, "Invalid user ID", err)
+			return
+		}
+		filter["user_id"] = objectID
+	}
+
+	if habitType := c.Query("type"); habitType != "" {
+		filter["type"] = habitType
+	}
+
+	if status := c.Query("status"); status != "" {
+		filter["status"] = status
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := db.HabitColl.Find(ctx, filter, findOptions)
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var habits []models.Habit
+	if err = cursor.All(ctx, &habits); err != nil {
+		SendInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, habits)
+}
+
+// GetHabitById returns a habit by ID
+func GetHabitById(c *gin.Context) {
+	habitID, err := validateAndGetHabitID(c)
+	if err != nil {
+		return
+	}
+
+	habit, err := fetchHabitByID(c, habitID)
+	if err != nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, habit)
+}
+
+// validateAndGetHabitID validates the habit ID from the request
+func validateAndGetHabitID(c *gin.Context) (primitive.ObjectID, error) {
+	id := c.Param("id")
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		SendBadRequest(c, "Invalid habit ID", err)
+		return primitive.NilObjectID, err
+	}
+	return objectID, nil
+}
+
+// fetchHabitByID retrieves a habit by its ID
+func fetchHabitByID(c *gin.Context, habitID primitive.ObjectID) (models.Habit, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var habit models.Habit
+	err := db.HabitColl.FindOne(ctx, bson.M{"_id": habitID, "deleted_at": nil}).Decode(&habit)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			SendNotFound(c, "Habit not found")
+			return models.Habit{}, err
+		}
+		SendInternalError(c, err)
+		return models.Habit{}, err
+	}
+	return habit, nil
+}
+
+// CreateHabit creates a new habit
+func CreateHabit(c *gin.Context) {
+	habit, err := parseAndValidateHabit(c)
+	if err != nil {
+		return
+	}
+
+	if err := validateUserExists(c, habit.UserID); err != nil {
+		return
+	}
+
+	if habit.PredecessorID != nil {
+		if err := validatePredecessor(c, primitive.NilObjectID, habit.UserID, *habit.PredecessorID); err != nil {
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := db.HabitColl.InsertOne(ctx, habit)
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	habit.ID = result.InsertedID.(primitive.ObjectID)
+
+	c.JSON(http.StatusCreated, habit)
+}
+
+// parseAndValidateHabit parses and validates the habit from the request body
+func parseAndValidateHabit(c *gin.Context) (models.Habit, error) {
+	var habit models.Habit
+	if err := c.ShouldBindJSON(&habit); err != nil {
+		SendBadRequest(c, "Invalid request body", err)
+		return models.Habit{}, err
+	}
+	return validateHabit(c, habit)
+}
+
+// validateHabit validates a new habit and fills in its defaults
+func validateHabit(c *gin.Context, habit models.Habit) (models.Habit, error) {
+	habit.Name = strings.TrimSpace(habit.Name)
+	if habit.Name == "" {
+		SendBadRequest(c, "Habit name is required", nil)
+		return models.Habit{}, fmt.Errorf("habit name is required")
+	}
+
+	if habit.Type == "" {
+		habit.Type = models.HabitTypeBuild
+	}
+	if habit.Type != models.HabitTypeBuild && habit.Type != models.HabitTypeAvoid {
+		SendBadRequest(c, "Habit type must be 'build' or 'avoid'", nil)
+		return models.Habit{}, fmt.Errorf("invalid habit type: %s", habit.Type)
+	}
+	if habit.PredecessorID != nil && habit.Type != models.HabitTypeBuild {
+		SendBadRequest(c, "Only build habits can be stacked", nil)
+		return models.Habit{}, fmt.Errorf("avoid habits cannot have a predecessor")
+	}
+
+	if habit.StartDate == "" {
+		habit.StartDate = time.Now().Format(dateLayout)
+	}
+	if _, err := time.Parse(dateLayout, habit.StartDate); err != nil {
+		SendBadRequest(c, "Start date must be in YYYY-MM-DD format", err)
+		return models.Habit{}, err
+	}
+
+	if err := validateWeekdays(c, habit.Weekdays); err != nil {
+		return models.Habit{}, err
+	}
+
+	reminders, err := normalizeReminders(c, habit.Reminders)
+	if err != nil {
+		return models.Habit{}, err
+	}
+	habit.Reminders = reminders
+
+	if habit.Target < 0 {
+		SendBadRequest(c, "Habit target must not be negative", nil)
+		return models.Habit{}, fmt.Errorf("negative habit target")
+	}
+	habit.Unit = strings.TrimSpace(habit.Unit)
+	habit.Category = strings.TrimSpace(habit.Category)
+
+	if habit.Difficulty == "" {
+		habit.Difficulty = models.DifficultyMedium
+	}
+	if err := validateDifficulty(c, habit.Difficulty); err != nil {
+		return models.Habit{}, err
+	}
+
+	if habit.Status == "" {
+		habit.Status = models.HabitStatusActive
+	}
+	if err := validateHabitStatus(c, habit.Status); err != nil {
+		return models.Habit{}, err
+	}
+
+	habit.CreatedAt = time.Now()
+	habit.PausedAt = nil
+	habit.Pauses = nil
+	if habit.Status != models.HabitStatusActive {
+		habit.PausedAt = &habit.CreatedAt
+	}
+	if habit.Status == models.HabitStatusPaused {
+		habit.Pauses = []models.HabitPause{{Start: habit.CreatedAt}}
+	}
+	habit.DeletedAt = nil
+
+	return habit, nil
+}
+
+// updatePauses opens a pause when a habit is paused and ends the open one when
+// it is resumed or archived
+func updatePauses(pauses []models.HabitPause, status string, now time.Time) []models.HabitPause {
+	open := len(pauses) > 0 && pauses[len(pauses)-1].End == nil
+	switch {
+	case status == models.HabitStatusPaused && !open:
+		return append(pauses, models.HabitPause{Start: now})
+	case status != models.HabitStatusPaused && open:
+		pauses = append([]models.HabitPause(nil), pauses...)
+		pauses[len(pauses)-1].End = &now
+	}
+	return pauses
+}
+
+// validateWeekdays checks that a schedule only lists weekdays 0 (Sunday) to 6
+func validateWeekdays(c *gin.Context, weekdays []int) error {
+	for _, weekday := range weekdays {
+		if weekday < 0 || weekday > 6 {
+			SendBadRequest(c, "Weekdays must be between 0 (Sunday) and 6 (Saturday)", nil)
+			return fmt.Errorf("invalid weekday: %d", weekday)
+		}
+	}
+	return nil
+}
+
+// normalizeReminders validates HH:MM reminder times and returns them sorted
+// without duplicates
+func normalizeReminders(c *gin.Context, reminders []string) ([]string, error) {
+	seen := make(map[string]bool, len(reminders))
+	normalized := []string{}
+	for _, reminder := range reminders {
+		minutes, err := schedule.ParseClock(strings.TrimSpace(reminder))
+		if err != nil {
+			SendBadRequest(c, "Reminder times must be in HH:MM format", err)
+			return nil, err
+		}
+		clock := fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+		if !seen[clock] {
+			seen[clock] = true
+			normalized = append(normalized, clock)
+		}
+	}
+	sort.Strings(normalized)
+	if len(normalized) == 0 {
+		return nil, nil
+	}
+	return normalized, nil
+}
+
+// validateDifficulty checks that a difficulty is one of the known difficulties
+func validateDifficulty(c *gin.Context, difficulty string) error {
+	switch difficulty {
+	case models.DifficultyEasy, models.DifficultyMedium, models.DifficultyHard:
+		return nil
+	}
+	SendBadRequest(c, "Habit difficulty must be 'easy', 'medium' or 'hard'", nil)
+	return fmt.Errorf("invalid habit difficulty: %s", difficulty)
+}
+
+// validateHabitStatus checks that a status is one of the known habit statuses
+func validateHabitStatus(c *gin.Context, status string) error {
+	switch status {
+	case models.HabitStatusActive, models.HabitStatusPaused, models.HabitStatusArchived:
+		return nil
+	}
+	SendBadRequest(c, "Habit status must be 'active', 'paused' or 'archived'", nil)
+	return fmt.Errorf("invalid habit status: %s", status)
+}
+
+// UpdateHabit updates a habit's name, start date, schedule, reminders, target,
+// category, difficulty, status or predecessor.
+// An empty predecessor_id removes the habit from its chain.
+func UpdateHabit(c *gin.Context) {
+	habitID, err := validateAndGetHabitID(c)
+	if err != nil {
+		return
+	}
+
+	habit, err := fetchHabitByID(c, habitID)
+	if err != nil {
+		return
+	}
+
+	update, err := parseHabitUpdateData(c, habit)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var updatedHabit models.Habit
+	err = db.HabitColl.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": habitID, "deleted_at": nil},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updatedHabit)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			SendNotFound(c, "Habit not found")
+			return
+		}
+		SendInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, updatedHabit)
+}
+
+// parseHabitUpdateData parses the update data from the request body into a
+// Mongo update document for the given habit
+func parseHabitUpdateData(c *gin.Context, habit models.Habit) (bson.M, error) {
+	var updateData struct {
+		Name          *string   `json:"name"`
+		StartDate     *string   `json:"start_date"`
+		PredecessorID *string   `json:"predecessor_id"`
+		Weekdays      *[]int    `json:"weekdays"`
+		Reminders     *[]string `json:"reminders"`
+		Status        *string   `json:"status"`
+		Difficulty    *string   `json:"difficulty"`
+		Target        *float64  `json:"target"`
+		Unit          *string   `json:"unit"`
+		Category      *string   `json:"category"`
+	}
+	if err := c.ShouldBindJSON(&updateData); err != nil {
+		SendBadRequest(c, "Invalid request body", err)
+		return nil, err
+	}
+
+	set := bson.M{}
+	unset := bson.M{}
+
+	if updateData.Name != nil {
+		name := strings.TrimSpace(*updateData.Name)
+		if name == "" {
+			SendBadRequest(c, "Habit name is required", nil)
+			return nil, fmt.Errorf("habit name is required")
+		}
+		set["name"] = name
+	}
+	if updateData.StartDate != nil {
+		if _, err := time.Parse(dateLayout, *updateData.StartDate); err != nil {
+			SendBadRequest(c, "Start date must be in YYYY-MM-DD format", err)
+			return nil, err
+		}
+		set["start_date"] = *updateData.StartDate
+	}
+	if updateData.Weekdays != nil {
+		if err := validateWeekdays(c, *updateData.Weekdays); err != nil {
+			return nil, err
+		}
+		set["weekdays"] = *updateData.Weekdays
+	}
+	if updateData.Reminders != nil {
+		reminders, err := normalizeReminders(c, *updateData.Reminders)
+		if err != nil {
+			return nil, err
+		}
+		if len(reminders) == 0 {
+			unset["reminders"] = ""
+		} else {
+			set["reminders"] = reminders
+		}
+	}
+
+	if updateData.Target != nil {
+		if *updateData.Target < 0 {
+			SendBadRequest(c, "Habit target must not be negative", nil)
+			return nil, fmt.Errorf("negative habit target")
+		}
+		set["target"] = *updateData.Target
+	}
+	if updateData.Unit != nil {
+		set["unit"] = strings.TrimSpace(*updateData.Unit)
+	}
+	if updateData.Category != nil {
+		set["category"] = strings.TrimSpace(*updateData.Category)
+	}
+
+	if updateData.Difficulty != nil {
+		if err := validateDifficulty(c, *updateData.Difficulty); err != nil {
+			return nil, err
+		}
+		set["difficulty"] = *updateData.Difficulty
+	}
+
+	if updateData.Status != nil {
+		if err := validateHabitStatus(c, *updateData.Status); err != nil {
+			return nil, err
+		}
+		set["status"] = *updateData.Status
+		// paused_at remembers when the habit first stopped being active
+		if *updateData.Status == models.HabitStatusActive {
+			unset["paused_at"] = ""
+		} else if habit.PausedAt == nil {
+			set["paused_at"] = time.Now()
+		}
+		set["pauses"] = updatePauses(habit.Pauses, *updateData.Status, time.Now())
+	}
+
+	if updateData.PredecessorID != nil {
+		if *updateData.PredecessorID == "" {
+			unset["predecessor_id"] = ""
+		} else {
+			predecessorID, err := primitive.ObjectIDFromHex(*updateData.PredecessorID)
+			if err != nil {
+				SendBadRequest(c, "Invalid predecessor ID", err)
+				return nil, err
+			}
+			if habit.Type != models.HabitTypeBuild {
+				SendBadRequest(c, "Only build habits can be stacked", nil)
+				return nil, fmt.Errorf("avoid habits cannot have a predecessor")
+			}
+			if err := validatePredecessor(c, habit.ID, habit.UserID, predecessorID); err != nil {
+				return nil, err
+			}
+			set["predecessor_id"] = predecessorID
+		}
+	}
+
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	if len(update) == 0 {
+		SendBadRequest(c, "No valid fields to update", nil)
+		return nil, fmt.Errorf("no valid fields to update")
+	}
+	set["updated_at"] = time.Now()
+	update["$set"] = set
+	update["$inc"] = bson.M{"revision": 1}
+
+	return update, nil
+}
+
+// DeleteHabit moves a habit to the trash. Its tasks and relapses are kept so the
+// habit can be restored; habits stacked on it start their own chains meanwhile.
+func DeleteHabit(c *gin.Context) {
+	habitID, err := validateAndGetHabitID(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := db.HabitColl.UpdateOne(
+		ctx,
+		bson.M{"_id": habitID, "deleted_at": nil},
+		bson.M{"$set": bson.M{"deleted_at": time.Now()}},
+	)
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	if result.MatchedCount == 0 {
+		SendNotFound(c, "Habit not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Habit moved to trash"})
+}
+
+// GenerateHabitTasks creates the day's tasks for a user's active build habits
+// that are scheduled on that day and do not have a task yet. Paused, archived
+// and deleted habits are skipped.
+func GenerateHabitTasks(c *gin.Context) {
+	var body struct {
+		UserID string `json:"user_id"`
+		Date   string `json:"date"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		SendBadRequest(c, "Invalid request body", err)
+		return
+	}
+
+	userID, err := primitive.ObjectIDFromHex(body.UserID)
+	if err != nil {
+		SendBadRequest(c, "Invalid user ID", err)
+		return
+	}
+
+	if body.Date == "" {
+		body.Date = time.Now().Format(dateLayout)
+	}
+	day, err := time.ParseInLocation(dateLayout, body.Date, time.Local)
+	if err != nil {
+		SendBadRequest(c, "Date must be in YYYY-MM-DD format", err)
+		return
+	}
+
+	habits, err := fetchBuildHabits(c, userID)
+	if err != nil {
+		return
+	}
+
+	existing, err := fetchTasksWithFilter(c, bson.M{"user_id": userID, "date": dayFilter(day)})
+	if err != nil {
+		return
+	}
+	hasTask := make(map[primitive.ObjectID]bool, len(existing))
+	for _, task := range existing {
+		if task.HabitID != nil {
+			hasTask[*task.HabitID] = true
+		}
+	}
+
+	position, err := nextTaskPosition(c, userID, body.Date)
+	if err != nil {
+		return
+	}
+
+	created := []models.Task{}
+	for _, habit := range habits {
+		if habit.Status != models.HabitStatusActive || hasTask[habit.ID] || !schedule.IsScheduledOn(habit, day) {
+			continue
+		}
+
+		habitID := habit.ID
+		task, err := insertTask(c, models.Task{
+			UserID:    userID,
+			HabitID:   &habitID,
+			Name:      habit.Name,
+			Date:      body.Date,
+			Position:  position,
+			CreatedAt: time.Now(),
+		})
+		if err != nil {
+			return
+		}
+		publishEvent(userID, models.EventTaskCreated, task)
+		created = append(created, task)
+		position++
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// validatePredecessor checks that a predecessor habit belongs to the same user,
+// is a build habit and does not make the chain loop back onto habitID
+func validatePredecessor(c *gin.Context, habitID, userID, predecessorID primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	current := predecessorID
+	for {
+		if current == habitID {
+			SendBadRequest(c, "Predecessor would create a cycle in the habit chain", nil)
+			return fmt.Errorf("habit chain cycle through %s", habitID.Hex())
+		}
+
+		var habit models.Habit
+		err := db.HabitColl.FindOne(ctx, bson.M{"_id": current, "deleted_at": nil}).Decode(&habit)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				SendNotFound(c, "Predecessor habit not found")
+				return err
+			}
+			SendInternalError(c, err)
+			return err
+		}
+
+		if habit.UserID != userID {
+			SendBadRequest(c, "Predecessor habit belongs to another user", nil)
+			return fmt.Errorf("predecessor %s belongs to another user", current.Hex())
+		}
+		if current == predecessorID && habit.Type != models.HabitTypeBuild {
+			SendBadRequest(c, "Only build habits can be stacked", nil)
+			return fmt.Errorf("predecessor %s is not a build habit", current.Hex())
+		}
+
+		if habit.PredecessorID == nil {
+			return nil
+		}
+		current = *habit.PredecessorID
+	}
+}
+
+// validateTaskHabit checks that a task's habit exists, belongs to the task's
+// user and is a build habit
+func validateTaskHabit(c *gin.Context, habitID, userID primitive.ObjectID) error {
+	habit, err := fetchHabitByID(c, habitID)
+	if err != nil {
+		return err
+	}
+	if habit.UserID != userID {
+		SendBadRequest(c, "Habit belongs to another user", nil)
+		return fmt.Errorf("habit %s belongs to another user", habitID.Hex())
+	}
+	if habit.Type != models.HabitTypeBuild {
+		SendBadRequest(c, "Tasks can only be created for build habits", nil)
+		return fmt.Errorf("habit %s is not a build habit", habitID.Hex())
+	}
+	return nil
+}
+
+// LogRelapse records a relapse for an avoid habit
+func LogRelapse(c *gin.Context) {
+	habitID, err := validateAndGetHabitID(c)
+	if err != nil {
+		return
+	}
+
+	habit, err := fetchAvoidHabit(c, habitID)
+	if err != nil {
+		return
+	}
+
+	relapse, err := parseAndValidateRelapse(c, habit)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := db.RelapseColl.InsertOne(ctx, relapse)
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	relapse.ID = result.InsertedID.(primitive.ObjectID)
+
+	c.JSON(http.StatusCreated, relapse)
+}
+
+// fetchAvoidHabit retrieves a habit and ensures it is an avoid habit
+func fetchAvoidHabit(c *gin.Context, habitID primitive.ObjectID) (models.Habit, error) {
+	habit, err := fetchHabitByID(c, habitID)
+	if err != nil {
+		return models.Habit{}, err
+	}
+	if habit.Type != models.HabitTypeAvoid {
+		SendBadRequest(c, "Relapses can only be tracked for avoid habits", nil)
+		return models.Habit{}, fmt.Errorf("habit %s is not an avoid habit", habitID.Hex())
+	}
+	return habit, nil
+}
+
+// parseAndValidateRelapse parses the relapse from the request body.
+// The body is optional; the relapse defaults to the current time.
+func parseAndValidateRelapse(c *gin.Context, habit models.Habit) (models.Relapse, error) {
+	var body struct {
+		OccurredAt *time.Time `json:"occurred_at"`
+		Note       string     `json:"note"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			SendBadRequest(c, "Invalid request body", err)
+			return models.Relapse{}, err
+		}
+	}
+
+	now := time.Now()
+	occurredAt := now
+	if body.OccurredAt != nil {
+		occurredAt = *body.OccurredAt
+	}
+	if occurredAt.After(now) {
+		SendBadRequest(c, "Relapse cannot be in the future", nil)
+		return models.Relapse{}, fmt.Errorf("relapse in the future")
+	}
+
+	date := occurredAt.Format(dateLayout)
+	if date < habit.StartDate {
+		SendBadRequest(c, "Relapse cannot be before the habit start date", nil)
+		return models.Relapse{}, fmt.Errorf("relapse before start date")
+	}
+
+	return models.Relapse{
+		HabitID:    habit.ID,
+		UserID:     habit.UserID,
+		Date:       date,
+		Note:       strings.TrimSpace(body.Note),
+		OccurredAt: occurredAt,
+		CreatedAt:  now,
+	}, nil
+}
+
+// GetRelapses returns the relapse log of a habit, most recent first
+func GetRelapses(c *gin.Context) {
+	habitID, err := validateAndGetHabitID(c)
+	if err != nil {
+		return
+	}
+
+	if _, err := fetchAvoidHabit(c, habitID); err != nil {
+		return
+	}
+
+	relapses, err := fetchRelapses(c, habitID)
+	if err != nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, relapses)
+}
+
+// fetchRelapses retrieves all relapses for a habit, most recent first
+func fetchRelapses(c *gin.Context, habitID primitive.ObjectID) ([]models.Relapse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "occurred_at", Value: -1}})
+	cursor, err := db.RelapseColl.Find(ctx, bson.M{"habit_id": habitID}, findOptions)
+	if err != nil {
+		SendInternalError(c, err)
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var relapses []models.Relapse
+	if err = cursor.All(ctx, &relapses); err != nil {
+		SendInternalError(c, err)
+		return nil, err
+	}
+	return relapses, nil
+}
+
+// DeleteRelapse removes a relapse logged by mistake
+func DeleteRelapse(c *gin.Context) {
+	habitID, err := validateAndGetHabitID(c)
+	if err != nil {
+		return
+	}
+
+	relapseID, err := primitive.ObjectIDFromHex(c.Param("relapseId"))
+	if err != nil {
+		SendBadRequest(c, "Invalid relapse ID", err)
+		return
+	}
+
+	if _, err := fetchAvoidHabit(c, habitID); err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := db.RelapseColl.DeleteOne(ctx, bson.M{"_id": relapseID, "habit_id": habitID})
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	if result.DeletedCount == 0 {
+		SendNotFound(c, "Relapse not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Relapse deleted successfully"})
+}
+
+// GetAbstinence returns the abstinence summary of an avoid habit
+func GetAbstinence(c *gin.Context) {
+	habitID, err := validateAndGetHabitID(c)
+	if err != nil {
+		return
+	}
+
+	habit, err := fetchAvoidHabit(c, habitID)
+	if err != nil {
+		return
+	}
+
+	relapses, err := fetchRelapses(c, habitID)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	relapseDates := make([]string, 0, len(relapses))
+	for _, relapse := range relapses {
+		relapseDates = append(relapseDates, relapse.Date)
+	}
+	current, longest := calculateAbstinenceStreak(habit.StartDate, relapseDates, now)
+
+	response := gin.H{
+		"habit_id":       habit.ID.Hex(),
+		"streak":         current,
+		"longest_streak": longest,
+		"total_relapses": len(relapses),
+		"last_relapse":   nil,
+	}
+
+	// Time since last relapse falls back to the habit start when there is none
+	since, err := time.ParseInLocation(dateLayout, habit.StartDate, now.Location())
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	if len(relapses) > 0 {
+		response["last_relapse"] = relapses[0]
+		since = relapses[0].OccurredAt
+	}
+	response["seconds_since_last_relapse"] = int64(now.Sub(since).Seconds())
+
+	c.JSON(http.StatusOK, response)
+}
+
+// calculateAbstinenceStreak determines the current and longest runs of days without
+// a relapse for an avoid habit. Unlike streaks.Current, every day from the start
+// date counts as a success unless a relapse was logged on it, and today counts
+// as soon as it begins.
+func calculateAbstinenceStreak(startDate string, relapseDates []string, now time.Time) (int, int) {
+	start, err := time.ParseInLocation(dateLayout, startDate, now.Location())
+	if err != nil {
+		return 0, 0
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if start.After(today) {
+		return 0, 0
+	}
+
+	relapsed := make(map[string]bool, len(relapseDates))
+	for _, date := range relapseDates {
+		relapsed[date] = true
+	}
+
+	// Walk the sorted relapse days and measure the clean gaps between them
+	days := make([]string, 0, len(relapsed))
+	for date := range relapsed {
+		days = append(days, date)
+	}
+	sort.Strings(days)
+
+	longest := 0
+	runStart := start
+	for _, date := range days {
+		day, err := time.ParseInLocation(dateLayout, date, now.Location())
+		if err != nil || day.Before(runStart) || day.After(today) {
+			continue
+		}
+		if run := daysBetween(runStart, day); run > longest {
+			longest = run
+		}
+		runStart = day.AddDate(0, 0, 1)
+	}
+
+	current := 0
+	if !runStart.After(today) {
+		current = daysBetween(runStart, today) + 1
+	}
+	if current > longest {
+		longest = current
+	}
+
+	return current, longest
+}
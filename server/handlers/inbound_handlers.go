@@ -0,0 +1,565 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"habit-tracker/server/db"
+	"habit-tracker/server/models"
+	"habit-tracker/server/schedule"
+	"habit-tracker/server/webhooks"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxInboundPayload caps the size of an event posted to an inbound hook
+const maxInboundPayload = 1 << 20
+
+// inboundProcessingTimeout is how long an event may stay in processing before
+// a replay may claim it again, in case the request handling it died
+const inboundProcessingTimeout = time.Minute
+
+// GetInboundHooks returns a user's inbound hooks
+func GetInboundHooks(c *gin.Context) {
+	userID, err := requireUserIDQuery(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := db.InboundHookColl.Find(ctx, bson.M{"user_id": userID}, findOptions)
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var hooks []models.InboundHook
+	if err = cursor.All(ctx, &hooks); err != nil {
+		SendInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, hooks)
+}
+
+// CreateInboundHook creates an inbound hook with a freshly generated token
+func CreateInboundHook(c *gin.Context) {
+	var hook models.InboundHook
+	if err := c.ShouldBindJSON(&hook); err != nil {
+		SendBadRequest(c, "Invalid request body", err)
+		return
+	}
+
+	hook.Name = strings.TrimSpace(hook.Name)
+	if hook.Name == "" {
+		SendBadRequest(c, "Inbound hook name is required", nil)
+		return
+	}
+
+	if err := validateUserExists(c, hook.UserID); err != nil {
+		return
+	}
+
+	rules, err := normalizeInboundRules(c, hook.UserID, hook.Rules)
+	if err != nil {
+		return
+	}
+	hook.Rules = rules
+
+	token, err := webhooks.NewSecret()
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	hook.Token = token
+	hook.Active = true
+	hook.CreatedAt = time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := db.InboundHookColl.InsertOne(ctx, hook)
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	hook.ID = result.InsertedID.(primitive.ObjectID)
+
+	c.JSON(http.StatusCreated, hook)
+}
+
+// UpdateInboundHook updates an inbound hook's name, rules or active flag
+func UpdateInboundHook(c *gin.Context) {
+	hookID, err := validateAndGetInboundHookID(c)
+	if err != nil {
+		return
+	}
+
+	var updateData struct {
+		Name   *string               `json:"name"`
+		Rules  *[]models.InboundRule `json:"rules"`
+		Active *bool                 `json:"active"`
+	}
+	if err := c.ShouldBindJSON(&updateData); err != nil {
+		SendBadRequest(c, "Invalid request body", err)
+		return
+	}
+
+	hook, err := fetchInboundHookByID(c, hookID)
+	if err != nil {
+		return
+	}
+
+	updateFields := bson.M{}
+	if updateData.Name != nil {
+		name := strings.TrimSpace(*updateData.Name)
+		if name == "" {
+			SendBadRequest(c, "Inbound hook name is required", nil)
+			return
+		}
+		updateFields["name"] = name
+	}
+	if updateData.Rules != nil {
+		rules, err := normalizeInboundRules(c, hook.UserID, *updateData.Rules)
+		if err != nil {
+			return
+		}
+		updateFields["rules"] = rules
+	}
+	if updateData.Active != nil {
+		updateFields["active"] = *updateData.Active
+	}
+	if len(updateFields) == 0 {
+		SendBadRequest(c, "No valid fields to update", nil)
+		return
+	}
+
+	updateInboundHook(c, hookID, updateFields)
+}
+
+// RotateInboundHookToken replaces an inbound hook's token. The old URL stops
+// working immediately.
+func RotateInboundHookToken(c *gin.Context) {
+	hookID, err := validateAndGetInboundHookID(c)
+	if err != nil {
+		return
+	}
+
+	token, err := webhooks.NewSecret()
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+
+	updateInboundHook(c, hookID, bson.M{"token": token})
+}
+
+// updateInboundHook applies $set fields to an inbound hook and returns it
+func updateInboundHook(c *gin.Context, hookID primitive.ObjectID, updateFields bson.M) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var updatedHook models.InboundHook
+	err := db.InboundHookColl.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": hookID},
+		bson.M{"$set": updateFields},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updatedHook)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			SendNotFound(c, "Inbound hook not found")
+			return
+		}
+		SendInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, updatedHook)
+}
+
+// DeleteInboundHook deletes an inbound hook. Its event log is kept.
+func DeleteInboundHook(c *gin.Context) {
+	hookID, err := validateAndGetInboundHookID(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := db.InboundHookColl.DeleteOne(ctx, bson.M{"_id": hookID})
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	if result.DeletedCount == 0 {
+		SendNotFound(c, "Inbound hook not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Inbound hook deleted successfully"})
+}
+
+// GetInboundEvents returns a page of the events received on an inbound hook,
+// most recent first
+func GetInboundEvents(c *gin.Context) {
+	hookID, err := validateAndGetInboundHookID(c)
+	if err != nil {
+		return
+	}
+
+	limit, offset, err := parsePagination(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"hook_id": hookID}
+	if status := c.Query("status"); status != "" {
+		filter["status"] = status
+	}
+	total, err := db.InboundEventColl.CountDocuments(ctx, filter)
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "received_at", Value: -1}}).
+		SetSkip(int64(offset)).
+		SetLimit(int64(limit))
+	cursor, err := db.InboundEventColl.Find(ctx, filter, findOptions)
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	events := []models.InboundEvent{}
+	if err = cursor.All(ctx, &events); err != nil {
+		SendInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events": events,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// ReceiveInboundEvent handles an event posted to an inbound hook URL. Every
+// rule matching the JSON payload completes its habit's task for the rule's
+// day, creating the task when there is none. Replays of an event, recognized
+// by a delivery header or by an identical payload, are acknowledged without
+// being applied again; replays of an event that failed are retried.
+func ReceiveInboundEvent(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var hook models.InboundHook
+	err := db.InboundHookColl.FindOne(ctx, bson.M{"token": c.Param("token"), "active": true}).Decode(&hook)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			SendNotFound(c, "Inbound hook not found")
+			return
+		}
+		SendInternalError(c, err)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxInboundPayload))
+	if err != nil {
+		SendBadRequest(c, "Could not read the request body", err)
+		return
+	}
+	var document interface{}
+	if err := json.Unmarshal(body, &document); err != nil {
+		SendBadRequest(c, "Payload must be JSON", err)
+		return
+	}
+
+	headerValue := ""
+	for _, header := range webhooks.DeliveryHeaders {
+		if headerValue = c.GetHeader(header); headerValue != "" {
+			break
+		}
+	}
+	key := webhooks.DeliveryKey(headerValue, body)
+
+	event, replay, err := recordInboundEvent(c, hook, key, body)
+	if err != nil {
+		return
+	}
+	if replay {
+		c.JSON(http.StatusOK, gin.H{"duplicate": true, "event": event})
+		return
+	}
+
+	event.Completions, event.Status, event.Error = applyInboundRules(hook, document, event.ReceivedAt)
+
+	_, err = db.InboundEventColl.UpdateOne(ctx, bson.M{"_id": event.ID}, bson.M{"$set": bson.M{
+		"completions": event.Completions,
+		"status":      event.Status,
+		"error":       event.Error,
+	}})
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"duplicate": false, "event": event})
+}
+
+// recordInboundEvent logs a received event. When the delivery key was seen
+// before it counts a replay and returns the logged event, unless that event
+// failed or has been processing for longer than inboundProcessingTimeout, in
+// which case it is claimed again for processing.
+func recordInboundEvent(c *gin.Context, hook models.InboundHook, key string, body []byte) (models.InboundEvent, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	event := models.InboundEvent{
+		HookID:      hook.ID,
+		UserID:      hook.UserID,
+		DeliveryKey: key,
+		Payload:     string(body),
+		Status:      models.InboundProcessing,
+		Completions: []models.InboundCompletion{},
+		ReceivedAt:  time.Now(),
+	}
+	result, err := db.InboundEventColl.InsertOne(ctx, event)
+	if err == nil {
+		event.ID = result.InsertedID.(primitive.ObjectID)
+		return event, false, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		SendInternalError(c, err)
+		return models.InboundEvent{}, false, err
+	}
+
+	seen := bson.M{"hook_id": hook.ID, "delivery_key": key}
+	findOptions := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	retry := bson.M{
+		"hook_id":      hook.ID,
+		"delivery_key": key,
+		"$or": bson.A{
+			bson.M{"status": models.InboundError},
+			bson.M{
+				"status":      models.InboundProcessing,
+				"received_at": bson.M{"$lte": event.ReceivedAt.Add(-inboundProcessingTimeout)},
+			},
+		},
+	}
+	err = db.InboundEventColl.FindOneAndUpdate(ctx, retry, bson.M{
+		"$set": bson.M{"status": models.InboundProcessing, "error": "", "received_at": event.ReceivedAt},
+		"$inc": bson.M{"replays": 1},
+	}, findOptions).Decode(&event)
+	if err == nil {
+		return event, false, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		SendInternalError(c, err)
+		return models.InboundEvent{}, false, err
+	}
+
+	err = db.InboundEventColl.FindOneAndUpdate(ctx, seen, bson.M{"$inc": bson.M{"replays": 1}}, findOptions).Decode(&event)
+	if err != nil {
+		SendInternalError(c, err)
+		return models.InboundEvent{}, false, err
+	}
+	return event, true, nil
+}
+
+// applyInboundRules completes the habits of every rule matching the payload and
+// returns the completions with the event's resulting status and error
+func applyInboundRules(hook models.InboundHook, document interface{}, received time.Time) ([]models.InboundCompletion, string, string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var user models.User
+	if err := db.UserColl.FindOne(ctx, bson.M{"_id": hook.UserID}).Decode(&user); err != nil {
+		return []models.InboundCompletion{}, models.InboundError, err.Error()
+	}
+	location := schedule.Location(user.Settings)
+
+	completions := []models.InboundCompletion{}
+	var problems []string
+	matched := false
+	for _, rule := range hook.Rules {
+		if !webhooks.Matches(rule, document) {
+			continue
+		}
+		matched = true
+
+		day, err := webhooks.RuleDay(rule, document, received, location)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+
+		completion, err := completeHabitOn(ctx, hook.UserID, rule.HabitID, day)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("habit %s: %v", rule.HabitID.Hex(), err))
+			continue
+		}
+		completions = append(completions, completion)
+	}
+
+	switch {
+	case len(problems) > 0:
+		return completions, models.InboundError, strings.Join(problems, "; ")
+	case !matched:
+		return completions, models.InboundIgnored, ""
+	}
+	return completions, models.InboundProcessed, ""
+}
+
+// completeHabitOn marks a habit's task for a day completed, creating it at the
+// end of the day when the habit has no task yet
+func completeHabitOn(ctx context.Context, userID, habitID primitive.ObjectID, day time.Time) (models.InboundCompletion, error) {
+	completion := models.InboundCompletion{HabitID: habitID, Date: day.Format(dateLayout)}
+
+	var habit models.Habit
+	err := db.HabitColl.FindOne(ctx, bson.M{"_id": habitID, "user_id": userID, "deleted_at": nil}).Decode(&habit)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return completion, fmt.Errorf("habit not found")
+		}
+		return completion, err
+	}
+
+	// Completing the task checks off its whole checklist, so the task stays
+	// completed exactly when all of its items are
+	filter := bson.M{"habit_id": habitID, "date": dayFilter(day), "deleted_at": nil}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"completed": true,
+			"items": bson.M{"$cond": bson.A{
+				bson.M{"$isArray": "$items"},
+				bson.M{"$map": bson.M{
+					"input": "$items",
+					"in":    bson.M{"$mergeObjects": bson.A{"$$this", bson.M{"completed": true}}},
+				}},
+				"$$REMOVE",
+			}},
+			"updated_at": "$$NOW",
+			"revision":   bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$revision", 0}}, 1}},
+		}}},
+	}
+	var previous models.Task
+	err = db.TaskColl.FindOneAndUpdate(ctx, filter, update).Decode(&previous)
+	if err == nil {
+		completion.TaskID = previous.ID
+		if !previous.Completed {
+			task := previous
+			task.Completed = true
+			task.Items = make([]models.ChecklistItem, len(previous.Items))
+			for i, item := range previous.Items {
+				item.Completed = true
+				task.Items[i] = item
+			}
+			afterTaskChange(&previous, task)
+		}
+		return completion, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return completion, err
+	}
+
+	var last models.Task
+	position := 0
+	findOptions := options.FindOne().SetSort(bson.D{{Key: "position", Value: -1}})
+	err = db.TaskColl.FindOne(ctx, bson.M{"user_id": userID, "date": dayFilter(day), "deleted_at": nil}, findOptions).Decode(&last)
+	switch err {
+	case nil:
+		position = last.Position + 1
+	case mongo.ErrNoDocuments:
+	default:
+		return completion, err
+	}
+
+	task := models.Task{
+		UserID:    userID,
+		HabitID:   &habitID,
+		Name:      habit.Name,
+		Completed: true,
+		Date:      completion.Date,
+		Position:  position,
+		CreatedAt: time.Now(),
+	}
+	result, err := db.TaskColl.InsertOne(ctx, task)
+	if err != nil {
+		return completion, err
+	}
+	task.ID = result.InsertedID.(primitive.ObjectID)
+	afterTaskChange(nil, task)
+
+	completion.TaskID = task.ID
+	completion.Created = true
+	return completion, nil
+}
+
+// normalizeInboundRules validates mapping rules against the user's habits
+func normalizeInboundRules(c *gin.Context, userID primitive.ObjectID, rules []models.InboundRule) ([]models.InboundRule, error) {
+	normalized := make([]models.InboundRule, 0, len(rules))
+	for _, rule := range rules {
+		rule.Path = strings.TrimSpace(rule.Path)
+		rule.DatePath = strings.TrimSpace(rule.DatePath)
+		if rule.Path == "" {
+			SendBadRequest(c, "Each rule needs a JSON path to match", nil)
+			return nil, fmt.Errorf("rule path is required")
+		}
+		if err := validateTaskHabit(c, rule.HabitID, userID); err != nil {
+			return nil, err
+		}
+		normalized = append(normalized, rule)
+	}
+	return normalized, nil
+}
+
+// validateAndGetInboundHookID validates the inbound hook ID from the request
+func validateAndGetInboundHookID(c *gin.Context) (primitive.ObjectID, error) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		SendBadRequest(c, "Invalid inbound hook ID", err)
+		return primitive.NilObjectID, err
+	}
+	return objectID, nil
+}
+
+// fetchInboundHookByID retrieves an inbound hook by its ID
+func fetchInboundHookByID(c *gin.Context, hookID primitive.ObjectID) (models.InboundHook, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var hook models.InboundHook
+	err := db.InboundHookColl.FindOne(ctx, bson.M{"_id": hookID}).Decode(&hook)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			SendNotFound(c, "Inbound hook not found")
+			return models.InboundHook{}, err
+		}
+		SendInternalError(c, err)
+		return models.InboundHook{}, err
+	}
+	return hook, nil
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"habit-tracker/server/insights"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// GetInsights returns the patterns found in a user's task history over a date
+// range, strongest first: habits completed together or apart and weekdays that
+// stand out
+func GetInsights(c *gin.Context) {
+	userID, err := requireUserIDQuery(c)
+	if err != nil {
+		return
+	}
+
+	start, end, err := parseDateRange(c, 90, 366)
+	if err != nil {
+		return
+	}
+
+	minSamples := insights.DefaultMinSamples
+	if value := c.Query("min_samples"); value != "" {
+		if minSamples, err = strconv.Atoi(value); err != nil || minSamples < 1 {
+			SendBadRequest(c, "min_samples must be a positive integer", err)
+			return
+		}
+	}
+
+	limit, offset, err := parsePagination(c)
+	if err != nil {
+		return
+	}
+
+	habits, err := fetchBuildHabits(c, userID)
+	if err != nil {
+		return
+	}
+
+	tasks, err := fetchTasksWithFilter(c, bson.M{
+		"user_id": userID,
+		"date":    dateRangeFilter(start, end),
+	})
+	if err != nil {
+		return
+	}
+
+	found := insights.Compute(tasks, habits, minSamples)
+	page := found[min(offset, len(found)):min(offset+limit, len(found))]
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id":     userID.Hex(),
+		"start_date":  start.Format(dateLayout),
+		"end_date":    end.Format(dateLayout),
+		"min_samples": minSamples,
+		"insights":    page,
+		"total":       len(found),
+		"limit":       limit,
+		"offset":      offset,
+	})
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"habit-tracker/server/db"
+	"habit-tracker/server/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetNotifications returns a page of a user's notifications, newest first,
+// with the unread count. unread=true lists only unread notifications.
+func GetNotifications(c *gin.Context) {
+	userID, err := validateAndGetUserID(c)
+	if err != nil {
+		return
+	}
+
+	limit, offset, err := parsePagination(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"user_id": userID}
+	if c.Query("unread") == "true" {
+		filter["read_at"] = nil
+	}
+	total, err := db.NotificationColl.CountDocuments(ctx, filter)
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+
+	unread, err := countUnreadNotifications(c, userID)
+	if err != nil {
+		return
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64(offset)).
+		SetLimit(int64(limit))
+	cursor, err := db.NotificationColl.Find(ctx, filter, findOptions)
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	notifications := []models.Notification{}
+	if err = cursor.All(ctx, &notifications); err != nil {
+		SendInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"notifications": notifications,
+		"total":         total,
+		"unread":        unread,
+		"limit":         limit,
+		"offset":        offset,
+	})
+}
+
+// GetUnreadNotificationCount returns how many notifications a user has not read
+func GetUnreadNotificationCount(c *gin.Context) {
+	userID, err := validateAndGetUserID(c)
+	if err != nil {
+		return
+	}
+
+	unread, err := countUnreadNotifications(c, userID)
+	if err != nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"unread": unread})
+}
+
+// countUnreadNotifications counts a user's unread notifications
+func countUnreadNotifications(c *gin.Context, userID primitive.ObjectID) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	unread, err := db.NotificationColl.CountDocuments(ctx, bson.M{"user_id": userID, "read_at": nil})
+	if err != nil {
+		SendInternalError(c, err)
+		return 0, err
+	}
+	return unread, nil
+}
+
+// MarkNotificationRead marks a notification as read. Marking it again keeps
+// the original read time.
+func MarkNotificationRead(c *gin.Context) {
+	notificationID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		SendBadRequest(c, "Invalid notification ID", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var notification models.Notification
+	err = db.NotificationColl.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": notificationID},
+		bson.A{bson.M{"$set": bson.M{"read_at": bson.M{"$ifNull": bson.A{"$read_at", time.Now()}}}}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&notification)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			SendNotFound(c, "Notification not found")
+			return
+		}
+		SendInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, notification)
+}
+
+// MarkAllNotificationsRead marks every unread notification of a user as read
+func MarkAllNotificationsRead(c *gin.Context) {
+	userID, err := validateAndGetUserID(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := db.NotificationColl.UpdateMany(
+		ctx,
+		bson.M{"user_id": userID, "read_at": nil},
+		bson.M{"$set": bson.M{"read_at": time.Now()}},
+	)
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updated": result.ModifiedCount, "unread": 0})
+}
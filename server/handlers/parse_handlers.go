@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"habit-tracker/server/db"
+	"habit-tracker/server/models"
+	"habit-tracker/server/parser"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ParseQuickAdd interprets quick-add text such as "run 5k every Mon Wed Fri
+// at 7am" and, when create is set, creates the resulting task or habit
+func ParseQuickAdd(c *gin.Context) {
+	var body struct {
+		UserID string `json:"user_id"`
+		Text   string `json:"text"`
+		Create bool   `json:"create"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		SendBadRequest(c, "Invalid request body", err)
+		return
+	}
+	if strings.TrimSpace(body.Text) == "" {
+		SendBadRequest(c, "Text is required", nil)
+		return
+	}
+
+	result, err := parser.Parse(body.Text, time.Now())
+	if err != nil {
+		SendBadRequest(c, "Could not understand the text", err)
+		return
+	}
+	if !body.Create {
+		c.JSON(http.StatusOK, gin.H{"interpretation": result})
+		return
+	}
+
+	// Only habits keep a time of day, as a reminder
+	if result.Kind == parser.KindTask && result.Time != "" {
+		SendBadRequest(c, "Tasks have no time of day; leave the time out or add a recurrence to make it a habit", nil)
+		return
+	}
+
+	userID, err := primitive.ObjectIDFromHex(body.UserID)
+	if err != nil {
+		SendBadRequest(c, "Invalid user ID", err)
+		return
+	}
+	if err := validateUserExists(c, userID); err != nil {
+		return
+	}
+
+	if result.Kind == parser.KindHabit {
+		habit, err := createParsedHabit(c, userID, result)
+		if err != nil {
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"interpretation": result, "habit": habit})
+		return
+	}
+
+	task, err := createParsedTask(c, userID, result)
+	if err != nil {
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"interpretation": result, "task": task})
+}
+
+// createParsedHabit creates a habit from a parsed interpretation, validated
+// and defaulted the same way CreateHabit does
+func createParsedHabit(c *gin.Context, userID primitive.ObjectID, result parser.Result) (models.Habit, error) {
+	habit := models.Habit{
+		UserID:    userID,
+		Name:      result.Name,
+		Type:      result.Type,
+		StartDate: result.StartDate,
+		Weekdays:  result.Weekdays,
+		Target:    result.Target,
+		Unit:      result.Unit,
+	}
+	if result.Time != "" {
+		habit.Reminders = []string{result.Time}
+	}
+
+	habit, err := validateHabit(c, habit)
+	if err != nil {
+		return models.Habit{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	inserted, err := db.HabitColl.InsertOne(ctx, habit)
+	if err != nil {
+		SendInternalError(c, err)
+		return models.Habit{}, err
+	}
+	habit.ID = inserted.InsertedID.(primitive.ObjectID)
+	return habit, nil
+}
+
+// createParsedTask creates a one-off task from a parsed interpretation
+func createParsedTask(c *gin.Context, userID primitive.ObjectID, result parser.Result) (models.Task, error) {
+	task := models.Task{
+		UserID:    userID,
+		Name:      result.Name,
+		Date:      result.Date,
+		CreatedAt: time.Now(),
+	}
+
+	position, err := nextTaskPosition(c, userID, task.Date)
+	if err != nil {
+		return models.Task{}, err
+	}
+	task.Position = position
+
+	createdTask, err := insertTask(c, task)
+	if err != nil {
+		return models.Task{}, err
+	}
+
+	afterTaskChange(nil, createdTask)
+	return createdTask, nil
+}
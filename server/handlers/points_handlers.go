@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"habit-tracker/server/db"
+	"habit-tracker/server/models"
+	"habit-tracker/server/points"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// pointsSummary is a user's level together with their spendable balance
+type pointsSummary struct {
+	points.LevelInfo
+	PointsSpent int `json:"points_spent"`
+	Balance     int `json:"balance"`
+}
+
+// GetUserPoints returns a user's XP, level and spendable balance
+func GetUserPoints(c *gin.Context) {
+	userID, err := validateAndGetUserID(c)
+	if err != nil {
+		return
+	}
+
+	user, err := fetchUserByID(c, userID)
+	if err != nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, pointsSummary{
+		LevelInfo:   points.Level(user.XP),
+		PointsSpent: user.PointsSpent,
+		Balance:     user.XP - user.PointsSpent,
+	})
+}
+
+// GetPointsLedger returns a page of a user's XP ledger, most recent first
+func GetPointsLedger(c *gin.Context) {
+	userID, err := validateAndGetUserID(c)
+	if err != nil {
+		return
+	}
+
+	limit, offset, err := parsePagination(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"user_id": userID}
+	total, err := db.PointsColl.CountDocuments(ctx, filter)
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64(offset)).
+		SetLimit(int64(limit))
+	cursor, err := db.PointsColl.Find(ctx, filter, findOptions)
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	entries := []models.PointsEntry{}
+	if err = cursor.All(ctx, &entries); err != nil {
+		SendInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+// RecomputeUserPoints re-scores a user's task history under the current rules
+func RecomputeUserPoints(c *gin.Context) {
+	userID, err := validateAndGetUserID(c)
+	if err != nil {
+		return
+	}
+
+	if err := validateUserExists(c, userID); err != nil {
+		return
+	}
+
+	history, err := points.LoadHistory(userID)
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+
+	adjustments, err := points.Recompute(userID, history)
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+
+	user, err := fetchUserByID(c, userID)
+	if err != nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"adjustments":   adjustments,
+		"rules_version": points.RulesVersion,
+		"level":         points.Level(user.XP),
+	})
+}
+
+// parsePagination reads the limit (default 50, at most 200) and offset query parameters
+func parsePagination(c *gin.Context) (int, int, error) {
+	limit, offset := 50, 0
+
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > 200 {
+			SendBadRequest(c, "limit must be between 1 and 200", err)
+			return 0, 0, errInvalidPagination
+		}
+		limit = parsed
+	}
+
+	if value := c.Query("offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			SendBadRequest(c, "offset must not be negative", err)
+			return 0, 0, errInvalidPagination
+		}
+		offset = parsed
+	}
+
+	return limit, offset, nil
+}
+
+// afterTaskChange runs the side effects of a task being created, updated,
+// deleted or restored; previous is the task before the change, or nil for a
+// new task. Failures are logged rather than failing the request.
+func afterTaskChange(previous *models.Task, task models.Task) {
+	// Points and achievements are both worked out from the same history
+	history, err := points.LoadHistory(task.UserID)
+	if err != nil {
+		log.Printf("Error loading history for user %s: %v", task.UserID.Hex(), err)
+	} else if _, err := points.SyncTask(task.ID, history); err != nil {
+		log.Printf("Error syncing points for task %s: %v", task.ID.Hex(), err)
+	}
+
+	if previous == nil {
+		publishEvent(task.UserID, models.EventTaskCreated, task)
+	}
+	if (previous == nil || !previous.Completed) && task.Completed && task.DeletedAt == nil {
+		publishEvent(task.UserID, models.EventTaskCompleted, task)
+	}
+
+	if err == nil {
+		evaluateAchievements(task.UserID, history)
+	}
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"habit-tracker/server/db"
+	"habit-tracker/server/models"
+	"habit-tracker/server/reports"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetReport generates a user's review of the week or month containing date
+func GetReport(c *gin.Context) {
+	userID, err := validateAndGetUserID(c)
+	if err != nil {
+		return
+	}
+
+	kind := c.DefaultQuery("period", models.ReportWeek)
+	day, err := parseDateParam(c, "date")
+	if err != nil {
+		return
+	}
+	period, err := reports.PeriodFor(kind, day)
+	if err != nil {
+		SendBadRequest(c, "Period must be 'week' or 'month'", err)
+		return
+	}
+
+	if err := validateUserExists(c, userID); err != nil {
+		return
+	}
+
+	report, err := reports.Generate(userID, period, time.Now())
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+
+	renderReport(c, report)
+}
+
+// GetSavedReports returns a page of a user's stored reports, most recent first
+func GetSavedReports(c *gin.Context) {
+	userID, err := validateAndGetUserID(c)
+	if err != nil {
+		return
+	}
+
+	limit, offset, err := parsePagination(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"user_id": userID}
+	if period := c.Query("period"); period != "" {
+		filter["period"] = period
+	}
+	total, err := db.ReportColl.CountDocuments(ctx, filter)
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "start_date", Value: -1}}).
+		SetSkip(int64(offset)).
+		SetLimit(int64(limit))
+	cursor, err := db.ReportColl.Find(ctx, filter, findOptions)
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	saved := []models.Report{}
+	if err = cursor.All(ctx, &saved); err != nil {
+		SendInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reports": saved,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+// GetSavedReport returns a stored report
+func GetSavedReport(c *gin.Context) {
+	reportID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		SendBadRequest(c, "Invalid report ID", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var report models.Report
+	if err := db.ReportColl.FindOne(ctx, bson.M{"_id": reportID}).Decode(&report); err != nil {
+		if err == mongo.ErrNoDocuments {
+			SendNotFound(c, "Report not found")
+			return
+		}
+		SendInternalError(c, err)
+		return
+	}
+
+	renderReport(c, report)
+}
+
+// renderReport writes a report in the requested format: json (the default),
+// markdown or html
+func renderReport(c *gin.Context, report models.Report) {
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+		c.JSON(http.StatusOK, report)
+	case "markdown", "md":
+		c.Data(http.StatusOK, "text/markdown; charset=utf-8", []byte(reports.Markdown(report)))
+	case "html":
+		page, err := reports.HTML(report)
+		if err != nil {
+			SendInternalError(c, err)
+			return
+		}
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(page))
+	default:
+		SendBadRequest(c, "Format must be 'json', 'markdown' or 'html'", nil)
+	}
+}
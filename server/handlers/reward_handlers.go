@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"habit-tracker/server/db"
+	"habit-tracker/server/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetRewards returns a user's rewards, cheapest first
+func GetRewards(c *gin.Context) {
+	userID, err := requireUserIDQuery(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "cost", Value: 1}})
+	cursor, err := db.RewardColl.Find(ctx, bson.M{"user_id": userID}, findOptions)
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var rewards []models.Reward
+	if err = cursor.All(ctx, &rewards); err != nil {
+		SendInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rewards)
+}
+
+// CreateReward creates a new reward
+func CreateReward(c *gin.Context) {
+	var reward models.Reward
+	if err := c.ShouldBindJSON(&reward); err != nil {
+		SendBadRequest(c, "Invalid request body", err)
+		return
+	}
+
+	reward.Name = strings.TrimSpace(reward.Name)
+	if reward.Name == "" {
+		SendBadRequest(c, "Reward name is required", nil)
+		return
+	}
+	if reward.Cost <= 0 {
+		SendBadRequest(c, "Reward cost must be positive", nil)
+		return
+	}
+
+	if err := validateUserExists(c, reward.UserID); err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	reward.CreatedAt = time.Now()
+	result, err := db.RewardColl.InsertOne(ctx, reward)
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	reward.ID = result.InsertedID.(primitive.ObjectID)
+
+	c.JSON(http.StatusCreated, reward)
+}
+
+// UpdateReward updates a reward's name, description or cost
+func UpdateReward(c *gin.Context) {
+	rewardID, err := validateAndGetRewardID(c)
+	if err != nil {
+		return
+	}
+
+	var updateData struct {
+		Name        *string `json:"name"`
+		Description *string `json:"description"`
+		Cost        *int    `json:"cost"`
+	}
+	if err := c.ShouldBindJSON(&updateData); err != nil {
+		SendBadRequest(c, "Invalid request body", err)
+		return
+	}
+
+	updateFields := bson.M{}
+	if updateData.Name != nil {
+		name := strings.TrimSpace(*updateData.Name)
+		if name == "" {
+			SendBadRequest(c, "Reward name is required", nil)
+			return
+		}
+		updateFields["name"] = name
+	}
+	if updateData.Description != nil {
+		updateFields["description"] = *updateData.Description
+	}
+	if updateData.Cost != nil {
+		if *updateData.Cost <= 0 {
+			SendBadRequest(c, "Reward cost must be positive", nil)
+			return
+		}
+		updateFields["cost"] = *updateData.Cost
+	}
+	if len(updateFields) == 0 {
+		SendBadRequest(c, "No valid fields to update", nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var updatedReward models.Reward
+	err = db.RewardColl.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": rewardID},
+		bson.M{"$set": updateFields},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updatedReward)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			SendNotFound(c, "Reward not found")
+			return
+		}
+		SendInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, updatedReward)
+}
+
+// DeleteReward deletes a reward. Past redemptions are kept.
+func DeleteReward(c *gin.Context) {
+	rewardID, err := validateAndGetRewardID(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := db.RewardColl.DeleteOne(ctx, bson.M{"_id": rewardID})
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	if result.DeletedCount == 0 {
+		SendNotFound(c, "Reward not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Reward deleted successfully"})
+}
+
+// RedeemReward spends a user's points on a reward
+func RedeemReward(c *gin.Context) {
+	rewardID, err := validateAndGetRewardID(c)
+	if err != nil {
+		return
+	}
+
+	reward, err := fetchRewardByID(c, rewardID)
+	if err != nil {
+		return
+	}
+
+	if err := spendPoints(c, reward.UserID, reward.Cost); err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	redemption := models.Redemption{
+		UserID:     reward.UserID,
+		RewardID:   reward.ID,
+		RewardName: reward.Name,
+		Cost:       reward.Cost,
+		RedeemedAt: time.Now(),
+	}
+	result, err := db.RedemptionColl.InsertOne(ctx, redemption)
+	if err != nil {
+		// Give the points back so a failed write does not cost the user anything
+		refund := bson.M{"$inc": bson.M{"points_spent": -reward.Cost}}
+		if _, refundErr := db.UserColl.UpdateOne(ctx, bson.M{"_id": reward.UserID}, refund); refundErr != nil {
+			log.Printf("Error refunding %d points to user %s: %v", reward.Cost, reward.UserID.Hex(), refundErr)
+		}
+		SendInternalError(c, err)
+		return
+	}
+	redemption.ID = result.InsertedID.(primitive.ObjectID)
+
+	c.JSON(http.StatusCreated, redemption)
+}
+
+// spendPoints deducts cost from a user's balance. The balance check and the
+// deduction are a single conditional update, so concurrent redemptions can
+// never overdraw the balance.
+func spendPoints(c *gin.Context, userID primitive.ObjectID, cost int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"_id": userID,
+		"$expr": bson.M{"$gte": bson.A{
+			bson.M{"$subtract": bson.A{
+				bson.M{"$ifNull": bson.A{"$xp", 0}},
+				bson.M{"$ifNull": bson.A{"$points_spent", 0}},
+			}},
+			cost,
+		}},
+	}
+	result, err := db.UserColl.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"points_spent": cost}})
+	if err != nil {
+		SendInternalError(c, err)
+		return err
+	}
+	if result.MatchedCount == 0 {
+		if err := validateUserExists(c, userID); err != nil {
+			return err
+		}
+		SendError(c, http.StatusConflict, "Not enough points to redeem this reward", nil)
+		return fmt.Errorf("insufficient points")
+	}
+	return nil
+}
+
+// GetRedemptions returns a page of a user's redemption history, most recent first
+func GetRedemptions(c *gin.Context) {
+	userID, err := requireUserIDQuery(c)
+	if err != nil {
+		return
+	}
+
+	limit, offset, err := parsePagination(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"user_id": userID}
+	total, err := db.RedemptionColl.CountDocuments(ctx, filter)
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "redeemed_at", Value: -1}}).
+		SetSkip(int64(offset)).
+		SetLimit(int64(limit))
+	cursor, err := db.RedemptionColl.Find(ctx, filter, findOptions)
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	redemptions := []models.Redemption{}
+	if err = cursor.All(ctx, &redemptions); err != nil {
+		SendInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"redemptions": redemptions,
+		"total":       total,
+		"limit":       limit,
+		"offset":      offset,
+	})
+}
+
+// validateAndGetRewardID validates the reward ID from the request
+func validateAndGetRewardID(c *gin.Context) (primitive.ObjectID, error) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		SendBadRequest(c, "Invalid reward ID", err)
+		return primitive.NilObjectID, err
+	}
+	return objectID, nil
+}
+
+// fetchRewardByID retrieves a reward by its ID
+func fetchRewardByID(c *gin.Context, rewardID primitive.ObjectID) (models.Reward, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var reward models.Reward
+	err := db.RewardColl.FindOne(ctx, bson.M{"_id": rewardID}).Decode(&reward)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			SendNotFound(c, "Reward not found")
+			return models.Reward{}, err
+		}
+		SendInternalError(c, err)
+		return models.Reward{}, err
+	}
+	return reward, nil
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"habit-tracker/server/db"
+	"habit-tracker/server/models"
+	"habit-tracker/server/schedule"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetUserSettings returns a user's reminder settings
+func GetUserSettings(c *gin.Context) {
+	userID, err := validateAndGetUserID(c)
+	if err != nil {
+		return
+	}
+
+	user, err := fetchUserByID(c, userID)
+	if err != nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, user.Settings)
+}
+
+// UpdateUserSettings updates a user's time zone, quiet hours or streak nudge
+// time. An empty value clears a setting.
+func UpdateUserSettings(c *gin.Context) {
+	userID, err := validateAndGetUserID(c)
+	if err != nil {
+		return
+	}
+
+	var updateData struct {
+		Timezone        *string `json:"timezone"`
+		QuietHoursStart *string `json:"quiet_hours_start"`
+		QuietHoursEnd   *string `json:"quiet_hours_end"`
+		NudgeTime       *string `json:"nudge_time"`
+	}
+	if err := c.ShouldBindJSON(&updateData); err != nil {
+		SendBadRequest(c, "Invalid request body", err)
+		return
+	}
+
+	user, err := fetchUserByID(c, userID)
+	if err != nil {
+		return
+	}
+
+	settings := user.Settings
+	if updateData.Timezone != nil {
+		settings.Timezone = strings.TrimSpace(*updateData.Timezone)
+	}
+	if updateData.QuietHoursStart != nil {
+		settings.QuietHoursStart = strings.TrimSpace(*updateData.QuietHoursStart)
+	}
+	if updateData.QuietHoursEnd != nil {
+		settings.QuietHoursEnd = strings.TrimSpace(*updateData.QuietHoursEnd)
+	}
+	if updateData.NudgeTime != nil {
+		settings.NudgeTime = strings.TrimSpace(*updateData.NudgeTime)
+	}
+	if err := validateUserSettings(c, settings); err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var updatedUser models.User
+	err = db.UserColl.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": userID},
+		bson.M{"$set": bson.M{"settings": settings}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updatedUser)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			SendNotFound(c, "User not found")
+			return
+		}
+		SendInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, updatedUser.Settings)
+}
+
+// validateUserSettings checks the time zone, that quiet hours are two HH:MM
+// times or not set at all, and the nudge time
+func validateUserSettings(c *gin.Context, settings models.UserSettings) error {
+	if settings.Timezone != "" {
+		if _, err := time.LoadLocation(settings.Timezone); err != nil {
+			SendBadRequest(c, "Unknown time zone", err)
+			return err
+		}
+	}
+
+	if (settings.QuietHoursStart == "") != (settings.QuietHoursEnd == "") {
+		SendBadRequest(c, "Quiet hours need both a start and an end", nil)
+		return fmt.Errorf("incomplete quiet hours")
+	}
+	for _, clock := range []string{settings.QuietHoursStart, settings.QuietHoursEnd} {
+		if clock == "" {
+			continue
+		}
+		if _, err := time.Parse(schedule.ClockLayout, clock); err != nil {
+			SendBadRequest(c, "Quiet hours must be in HH:MM format", err)
+			return err
+		}
+	}
+	if settings.NudgeTime != "" {
+		if _, err := time.Parse(schedule.ClockLayout, settings.NudgeTime); err != nil {
+			SendBadRequest(c, "Nudge time must be in HH:MM format", err)
+			return err
+		}
+	}
+	return nil
+}
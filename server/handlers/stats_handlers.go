@@ -0,0 +1,339 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"time"
+
+	"habit-tracker/server/db"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// periodStats is the completion of a day, ISO week (2024-W05) or month (2024-02)
+type periodStats struct {
+	Period    string  `bson:"_id" json:"period"`
+	Total     int     `bson:"total" json:"total"`
+	Completed int     `bson:"completed" json:"completed"`
+	Rate      float64 `bson:"-" json:"rate"`
+}
+
+// habitStats is the completion of a habit's tasks
+type habitStats struct {
+	HabitID   primitive.ObjectID `bson:"_id" json:"habit_id"`
+	Name      string             `bson:"name" json:"name"`
+	Total     int                `bson:"total" json:"total"`
+	Completed int                `bson:"completed" json:"completed"`
+	Rate      float64            `bson:"-" json:"rate"`
+}
+
+// weekdayStats is the completion on a weekday, 0 (Sunday) to 6 (Saturday)
+type weekdayStats struct {
+	Weekday   int     `bson:"_id" json:"weekday"`
+	Name      string  `bson:"-" json:"name"`
+	Total     int     `bson:"total" json:"total"`
+	Completed int     `bson:"completed" json:"completed"`
+	Rate      float64 `bson:"-" json:"rate"`
+}
+
+// statsFacets is the result of the stats aggregation
+type statsFacets struct {
+	ByDay      []periodStats  `bson:"by_day"`
+	ByWeek     []periodStats  `bson:"by_week"`
+	ByMonth    []periodStats  `bson:"by_month"`
+	ByHabit    []habitStats   `bson:"by_habit"`
+	ByWeekday  []weekdayStats `bson:"by_weekday"`
+	FrozenDays []struct {
+		Day string `bson:"_id"`
+	} `bson:"frozen_days"`
+}
+
+// GetStats returns completion statistics of a user over a date range: totals,
+// averages, rates per day, ISO week, month, habit and weekday, and the best and
+// worst weekday. Frozen-day marker tasks are not counted as tasks.
+func GetStats(c *gin.Context) {
+	userID, err := requireUserIDQuery(c)
+	if err != nil {
+		return
+	}
+
+	start, end, err := parseDateRange(c, 30, 366)
+	if err != nil {
+		return
+	}
+
+	facets, err := aggregateStats(c, userID, start, end)
+	if err != nil {
+		return
+	}
+
+	frozen := make(map[string]bool, len(facets.FrozenDays))
+	for _, day := range facets.FrozenDays {
+		frozen[day.Day] = true
+	}
+
+	var total, completed, perfectDays int
+	dailyRates := 0.0
+	for i := range facets.ByDay {
+		day := &facets.ByDay[i]
+		day.Rate = rate(day.Completed, day.Total)
+		total += day.Total
+		completed += day.Completed
+		dailyRates += day.Rate
+		if day.Completed == day.Total && !frozen[day.Period] {
+			perfectDays++
+		}
+	}
+	for i := range facets.ByWeek {
+		facets.ByWeek[i].Rate = rate(facets.ByWeek[i].Completed, facets.ByWeek[i].Total)
+	}
+	for i := range facets.ByMonth {
+		facets.ByMonth[i].Rate = rate(facets.ByMonth[i].Completed, facets.ByMonth[i].Total)
+	}
+	for i := range facets.ByHabit {
+		facets.ByHabit[i].Rate = rate(facets.ByHabit[i].Completed, facets.ByHabit[i].Total)
+	}
+
+	var best, worst *weekdayStats
+	for i := range facets.ByWeekday {
+		weekday := &facets.ByWeekday[i]
+		weekday.Name = time.Weekday(weekday.Weekday).String()
+		weekday.Rate = rate(weekday.Completed, weekday.Total)
+		if best == nil || weekday.Rate > best.Rate {
+			best = weekday
+		}
+		if worst == nil || weekday.Rate < worst.Rate {
+			worst = weekday
+		}
+	}
+
+	activeDays := len(facets.ByDay)
+	rangeDays := daysBetween(start, end) + 1
+	averages := gin.H{
+		"daily_rate":           0.0,
+		"tasks_per_active_day": 0.0,
+		"completed_per_day":    roundTo(float64(completed)/float64(rangeDays), 2),
+		"completed_per_week":   roundTo(float64(completed)*7/float64(rangeDays), 2),
+		"active_days_per_week": roundTo(float64(activeDays)*7/float64(rangeDays), 2),
+	}
+	if activeDays > 0 {
+		averages["daily_rate"] = roundTo(dailyRates/float64(activeDays), 4)
+		averages["tasks_per_active_day"] = roundTo(float64(total)/float64(activeDays), 2)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id":    userID.Hex(),
+		"start_date": start.Format(dateLayout),
+		"end_date":   end.Format(dateLayout),
+		"totals": gin.H{
+			"tasks":        total,
+			"completed":    completed,
+			"rate":         rate(completed, total),
+			"days":         rangeDays,
+			"active_days":  activeDays,
+			"perfect_days": perfectDays,
+			"frozen_days":  len(facets.FrozenDays),
+		},
+		"averages":      averages,
+		"best_weekday":  best,
+		"worst_weekday": worst,
+		"by_day":        facets.ByDay,
+		"by_week":       facets.ByWeek,
+		"by_month":      facets.ByMonth,
+		"by_habit":      facets.ByHabit,
+		"by_weekday":    facets.ByWeekday,
+	})
+}
+
+// aggregateStats groups a user's tasks in a date range by day, ISO week,
+// month, habit and weekday in a single aggregation
+func aggregateStats(c *gin.Context, userID primitive.ObjectID, start, end time.Time) (statsFacets, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// count sums tasks and completed tasks of a group
+	count := func(key interface{}) bson.D {
+		return bson.D{{Key: "$group", Value: bson.M{
+			"_id":       key,
+			"total":     bson.M{"$sum": 1},
+			"completed": bson.M{"$sum": bson.M{"$cond": bson.A{"$completed", 1, 0}}},
+		}}}
+	}
+	byID := bson.D{{Key: "$sort", Value: bson.M{"_id": 1}}}
+	counted := bson.M{"frozen": false}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"user_id":    userID,
+			"deleted_at": nil,
+			"date":       dateRangeFilter(start, end),
+		}}},
+		{{Key: "$addFields", Value: bson.M{
+			"day": bson.M{"$substrCP": bson.A{"$date", 0, 10}},
+			"frozen": bson.M{"$regexMatch": bson.M{
+				"input": "$name", "regex": "frozen", "options": "i",
+			}},
+		}}},
+		{{Key: "$addFields", Value: bson.M{
+			"day_date": bson.M{"$dateFromString": bson.M{
+				"dateString": "$day", "format": "%Y-%m-%d", "onError": nil, "onNull": nil,
+			}},
+		}}},
+		{{Key: "$match", Value: bson.M{"day_date": bson.M{"$ne": nil}}}},
+		{{Key: "$facet", Value: bson.M{
+			"by_day": bson.A{bson.M{"$match": counted}, count("$day"), byID},
+			"by_week": bson.A{
+				bson.M{"$match": counted},
+				count(bson.M{"$dateToString": bson.M{"format": "%G-W%V", "date": "$day_date"}}),
+				byID,
+			},
+			"by_month": bson.A{
+				bson.M{"$match": counted},
+				count(bson.M{"$substrCP": bson.A{"$day", 0, 7}}),
+				byID,
+			},
+			"by_weekday": bson.A{
+				bson.M{"$match": counted},
+				count(bson.M{"$subtract": bson.A{bson.M{"$dayOfWeek": "$day_date"}, 1}}),
+				byID,
+			},
+			"by_habit": bson.A{
+				bson.M{"$match": bson.M{"frozen": false, "habit_id": bson.M{"$ne": nil}}},
+				count("$habit_id"),
+				bson.M{"$lookup": bson.M{
+					"from": "habits", "localField": "_id", "foreignField": "_id", "as": "habit",
+				}},
+				bson.M{"$addFields": bson.M{
+					"name": bson.M{"$ifNull": bson.A{bson.M{"$arrayElemAt": bson.A{"$habit.name", 0}}, ""}},
+				}},
+				bson.M{"$project": bson.M{"habit": 0}},
+				bson.M{"$sort": bson.D{{Key: "total", Value: -1}, {Key: "_id", Value: 1}}},
+			},
+			"frozen_days": bson.A{
+				bson.M{"$match": bson.M{"frozen": true}},
+				bson.M{"$group": bson.M{"_id": "$day"}},
+			},
+		}}},
+	}
+
+	cursor, err := db.TaskColl.Aggregate(ctx, pipeline)
+	if err != nil {
+		SendInternalError(c, err)
+		return statsFacets{}, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []statsFacets
+	if err = cursor.All(ctx, &results); err != nil {
+		SendInternalError(c, err)
+		return statsFacets{}, err
+	}
+	if len(results) == 0 {
+		return statsFacets{}, nil
+	}
+	return results[0], nil
+}
+
+// heatmapLevels is the number of non-empty intensity buckets
+const heatmapLevels = 4
+
+// heatmapDay is one cell of a calendar heatmap
+type heatmapDay struct {
+	Date      string `bson:"_id" json:"date"`
+	Total     int    `bson:"total" json:"total"`
+	Completed int    `bson:"completed" json:"completed"`
+	Frozen    bool   `bson:"frozen" json:"frozen"`
+	Level     int    `bson:"-" json:"level"`
+}
+
+// GetHeatmap returns one cell per day of a date range (up to a year) with the
+// day's task count, completed count, freeze status and intensity level: 0 when
+// nothing was completed, otherwise 1-4 by the share of tasks completed
+func GetHeatmap(c *gin.Context) {
+	userID, err := requireUserIDQuery(c)
+	if err != nil {
+		return
+	}
+
+	start, end, err := parseDateRange(c, 365, 366)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	frozen := bson.M{"$regexMatch": bson.M{"input": "$name", "regex": "frozen", "options": "i"}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"user_id":    userID,
+			"deleted_at": nil,
+			"date":       dateRangeFilter(start, end),
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":       bson.M{"$substrCP": bson.A{"$date", 0, 10}},
+			"total":     bson.M{"$sum": bson.M{"$cond": bson.A{frozen, 0, 1}}},
+			"completed": bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$and": bson.A{"$completed", bson.M{"$not": frozen}}}, 1, 0}}},
+			"frozen":    bson.M{"$max": frozen},
+		}}},
+	}
+	cursor, err := db.TaskColl.Aggregate(ctx, pipeline)
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var grouped []heatmapDay
+	if err = cursor.All(ctx, &grouped); err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	byDate := make(map[string]heatmapDay, len(grouped))
+	for _, day := range grouped {
+		byDate[day.Date] = day
+	}
+
+	days := make([]heatmapDay, 0, daysBetween(start, end)+1)
+	activeDays, completed := 0, 0
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		date := day.Format(dateLayout)
+		cell, ok := byDate[date]
+		if !ok {
+			cell = heatmapDay{Date: date}
+		}
+		cell.Level = heatmapLevel(cell)
+		if cell.Total > 0 {
+			activeDays++
+		}
+		completed += cell.Completed
+		days = append(days, cell)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id":     userID.Hex(),
+		"start_date":  start.Format(dateLayout),
+		"end_date":    end.Format(dateLayout),
+		"levels":      heatmapLevels,
+		"active_days": activeDays,
+		"completed":   completed,
+		"days":        days,
+	})
+}
+
+// heatmapLevel buckets a day's completion rate into 1-4, so a day with any
+// completion is never shown as empty
+func heatmapLevel(day heatmapDay) int {
+	if day.Completed == 0 || day.Total == 0 {
+		return 0
+	}
+	level := int(math.Ceil(float64(day.Completed) / float64(day.Total) * heatmapLevels))
+	if level > heatmapLevels {
+		level = heatmapLevels
+	}
+	return level
+}
@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"habit-tracker/server/db"
+	"habit-tracker/server/models"
+	"habit-tracker/server/points"
+	"habit-tracker/server/taskcsv"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxImportBytes caps the size of an imported file
+const maxImportBytes = 5 << 20
+
+// Import row statuses
+const (
+	importCreated   = "created"
+	importValid     = "valid"
+	importDuplicate = "duplicate"
+	importError     = "error"
+)
+
+// importRow is the outcome of a single imported row
+type importRow struct {
+	Line   int      `json:"line"`
+	Status string   `json:"status"`
+	Name   string   `json:"name"`
+	Date   string   `json:"date"`
+	TaskID string   `json:"task_id,omitempty"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// ExportTasksCSV streams a user's tasks as CSV, optionally limited to a date
+// range
+func ExportTasksCSV(c *gin.Context) {
+	userID, err := requireUserIDQuery(c)
+	if err != nil {
+		return
+	}
+
+	filter := bson.M{"user_id": userID, "deleted_at": nil}
+	dates := bson.M{}
+	if c.Query("start_date") != "" {
+		start, err := parseDateParam(c, "start_date")
+		if err != nil {
+			return
+		}
+		dates["$gte"] = start.Format(dateLayout)
+	}
+	if c.Query("end_date") != "" {
+		end, err := parseDateParam(c, "end_date")
+		if err != nil {
+			return
+		}
+		dates["$lt"] = end.AddDate(0, 0, 1).Format(dateLayout)
+	}
+	if len(dates) > 0 {
+		filter["date"] = dates
+	}
+
+	if err := validateUserExists(c, userID); err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	cursor, err := db.TaskColl.Find(ctx, filter, options.Find().SetSort(taskSort))
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="tasks.csv"`)
+	c.Status(http.StatusOK)
+
+	// Headers are sent by now, so failures can only cut the file short
+	writer := csv.NewWriter(c.Writer)
+	if err := writer.Write(taskcsv.Columns); err != nil {
+		return
+	}
+	for rows := 1; cursor.Next(ctx); rows++ {
+		var task models.Task
+		if err := cursor.Decode(&task); err != nil {
+			log.Printf("Error exporting tasks of user %s: %v", userID.Hex(), err)
+			break
+		}
+		if err := writer.Write(taskcsv.Record(task)); err != nil {
+			return
+		}
+		if rows%100 == 0 {
+			writer.Flush()
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		log.Printf("Error exporting tasks of user %s: %v", userID.Hex(), err)
+	}
+	writer.Flush()
+}
+
+// ImportTasksCSV creates tasks from a CSV file sent as the request body or as
+// the "file" field of a multipart form. Rows are validated one by one; invalid
+// rows and rows matching an existing task by name and date are skipped and
+// reported. With dry_run=true nothing is written.
+func ImportTasksCSV(c *gin.Context) {
+	userID, err := requireUserIDQuery(c)
+	if err != nil {
+		return
+	}
+	dryRun := c.Query("dry_run") == "true"
+
+	if err := validateUserExists(c, userID); err != nil {
+		return
+	}
+
+	body, err := importBody(c, maxImportBytes)
+	if err != nil {
+		return
+	}
+	defer body.Close()
+
+	rows, unknown, err := taskcsv.Read(body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			SendError(c, http.StatusRequestEntityTooLarge, "File must not exceed 5 MB", err)
+			return
+		}
+		SendBadRequest(c, "Invalid CSV file", err)
+		return
+	}
+
+	habitIDs, err := fetchUserHabitIDs(c, userID)
+	if err != nil {
+		return
+	}
+	for i := range rows {
+		if habitID := rows[i].Task.HabitID; habitID != nil && !habitIDs[*habitID] {
+			rows[i].Errors = append(rows[i].Errors, "habit_id does not belong to the user")
+		}
+	}
+
+	existing, err := fetchTaskKeys(c, userID, rows)
+	if err != nil {
+		return
+	}
+
+	results := make([]importRow, 0, len(rows))
+	tasks := []models.Task{}
+	positions := make(map[string]int)
+	for _, row := range rows {
+		result := importRow{Line: row.Line, Status: importValid, Name: row.Task.Name, Date: row.Task.Date, Errors: row.Errors}
+		key := taskcsv.Key(row.Task.Name, row.Task.Date)
+		switch {
+		case len(row.Errors) > 0:
+			result.Status = importError
+		case existing[key]:
+			result.Status = importDuplicate
+		default:
+			existing[key] = true
+
+			task := row.Task
+			task.ID = primitive.NewObjectID()
+			task.UserID = userID
+			task.CreatedAt = time.Now()
+			if _, ok := positions[task.Date]; !ok {
+				if positions[task.Date], err = nextTaskPosition(c, userID, task.Date); err != nil {
+					return
+				}
+			}
+			task.Position = positions[task.Date]
+			positions[task.Date]++
+
+			tasks = append(tasks, task)
+			if !dryRun {
+				result.Status = importCreated
+				result.TaskID = task.ID.Hex()
+			}
+		}
+		results = append(results, result)
+	}
+
+	if !dryRun && len(tasks) > 0 {
+		if err := insertImportedTasks(c, userID, tasks); err != nil {
+			return
+		}
+	}
+
+	summary := gin.H{"dry_run": dryRun, "rows": len(results), "unknown_columns": unknown}
+	for _, status := range []string{importCreated, importValid, importDuplicate, importError} {
+		summary[status] = 0
+	}
+	for _, result := range results {
+		summary[result.Status] = summary[result.Status].(int) + 1
+	}
+	summary["results"] = results
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// importBody returns the uploaded file of an import request, of at most limit
+// bytes
+func importBody(c *gin.Context, limit int64) (io.ReadCloser, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+	if !strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		return c.Request.Body, nil
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		SendBadRequest(c, "A file is required in the file field", err)
+		return nil, err
+	}
+	file, err := header.Open()
+	if err != nil {
+		SendInternalError(c, err)
+		return nil, err
+	}
+	return file, nil
+}
+
+// fetchUserHabitIDs returns the IDs of a user's habits
+func fetchUserHabitIDs(c *gin.Context, userID primitive.ObjectID) (map[primitive.ObjectID]bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"user_id": userID, "deleted_at": nil}
+	cursor, err := db.HabitColl.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		SendInternalError(c, err)
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var habits []models.Habit
+	if err = cursor.All(ctx, &habits); err != nil {
+		SendInternalError(c, err)
+		return nil, err
+	}
+
+	ids := make(map[primitive.ObjectID]bool, len(habits))
+	for _, habit := range habits {
+		ids[habit.ID] = true
+	}
+	return ids, nil
+}
+
+// fetchTaskKeys returns the name and date keys of a user's tasks on the days
+// the imported rows fall on
+func fetchTaskKeys(c *gin.Context, userID primitive.ObjectID, rows []taskcsv.Row) (map[string]bool, error) {
+	keys := make(map[string]bool)
+	first, last := "", ""
+	for _, row := range rows {
+		if len(row.Errors) > 0 {
+			continue
+		}
+		if first == "" || row.Task.Date < first {
+			first = row.Task.Date
+		}
+		if row.Task.Date > last {
+			last = row.Task.Date
+		}
+	}
+	if first == "" {
+		return keys, nil
+	}
+
+	start, _ := time.ParseInLocation(dateLayout, first, time.Local)
+	end, _ := time.ParseInLocation(dateLayout, last, time.Local)
+	tasks, err := fetchTasksWithFilter(c, bson.M{"user_id": userID, "date": dateRangeFilter(start, end)})
+	if err != nil {
+		return nil, err
+	}
+	for _, task := range tasks {
+		keys[taskcsv.Key(task.Name, task.Date)] = true
+	}
+	return keys, nil
+}
+
+// insertImportedTasks stores imported tasks, then re-scores the user's history
+// and evaluates achievements once. Imported tasks are history rather than
+// activity, so no task webhooks are sent for them.
+func insertImportedTasks(c *gin.Context, userID primitive.ObjectID, tasks []models.Task) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	documents := make([]interface{}, 0, len(tasks))
+	for _, task := range tasks {
+		documents = append(documents, task)
+	}
+	if _, err := db.TaskColl.InsertMany(ctx, documents); err != nil {
+		SendInternalError(c, err)
+		return err
+	}
+
+	afterTasksImported(userID)
+	return nil
+}
+
+// afterTasksImported re-scores a user's history and evaluates achievements
+// once after a bulk import. Failures are logged rather than failing the request.
+func afterTasksImported(userID primitive.ObjectID) {
+	history, err := points.LoadHistory(userID)
+	if err != nil {
+		log.Printf("Error loading history for user %s: %v", userID.Hex(), err)
+		return
+	}
+	if _, err := points.Recompute(userID, history); err != nil {
+		log.Printf("Error recomputing points for user %s: %v", userID.Hex(), err)
+	}
+	evaluateAchievements(userID, history)
+}
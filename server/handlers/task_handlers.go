@@ -2,13 +2,16 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"strings"
 	"time"
 
 	"habit-tracker/server/db"
 	"habit-tracker/server/models"
+	"habit-tracker/server/streaks"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
@@ -61,12 +64,24 @@ func buildTaskFilter(c *gin.Context) (bson.M, error) {
 	return filter, nil
 }
 
-// fetchTasksWithFilter retrieves tasks based on the provided filter
+// taskSort orders tasks by day, then by their position within the day
+var taskSort = bson.D{
+	{Key: "date", Value: 1},
+	{Key: "position", Value: 1},
+	{Key: "created_at", Value: 1},
+}
+
+// fetchTasksWithFilter retrieves tasks based on the provided filter.
+// Tasks in the trash are left out unless the filter mentions deleted_at.
 func fetchTasksWithFilter(c *gin.Context, filter bson.M) ([]models.Task, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	cursor, err := db.TaskColl.Find(ctx, filter)
+	if _, ok := filter["deleted_at"]; !ok {
+		filter["deleted_at"] = nil
+	}
+
+	cursor, err := db.TaskColl.Find(ctx, filter, options.Find().SetSort(taskSort))
 	if err != nil {
 		SendInternalError(c, err)
 		return nil, err
@@ -108,11 +123,25 @@ func CreateTask(c *gin.Context) {
 		return
 	}
 
+	if task.HabitID != nil {
+		if err := validateTaskHabit(c, *task.HabitID, task.UserID); err != nil {
+			return
+		}
+	}
+
+	position, err := nextTaskPosition(c, task.UserID, task.Date)
+	if err != nil {
+		return
+	}
+	task.Position = position
+
 	createdTask, err := insertTask(c, task)
 	if err != nil {
 		return
 	}
 
+	afterTaskChange(nil, createdTask)
+
 	c.JSON(http.StatusCreated, createdTask)
 }
 
@@ -129,6 +158,15 @@ func parseAndValidateTask(c *gin.Context) (models.Task, error) {
 	}
 	task.CreatedAt = time.Now()
 
+	items, err := normalizeChecklistItems(c, task.Items)
+	if err != nil {
+		return models.Task{}, err
+	}
+	task.Items = items
+	if len(task.Items) > 0 {
+		task.Completed = allItemsCompleted(task.Items)
+	}
+
 	return task, nil
 }
 
@@ -150,6 +188,31 @@ func validateUserExists(c *gin.Context, userID primitive.ObjectID) error {
 	return nil
 }
 
+// nextTaskPosition returns the position after the last task of the user's day
+func nextTaskPosition(c *gin.Context, userID primitive.ObjectID, date string) (int, error) {
+	day, err := time.ParseInLocation(dateLayout, taskDay(date), time.Local)
+	if err != nil {
+		SendBadRequest(c, "Task date must start with YYYY-MM-DD", err)
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var last models.Task
+	findOptions := options.FindOne().SetSort(bson.D{{Key: "position", Value: -1}})
+	filter := bson.M{"user_id": userID, "date": dayFilter(day), "deleted_at": nil}
+	err = db.TaskColl.FindOne(ctx, filter, findOptions).Decode(&last)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return 0, nil
+		}
+		SendInternalError(c, err)
+		return 0, err
+	}
+	return last.Position + 1, nil
+}
+
 // insertTask inserts a new task into the database
 func insertTask(c *gin.Context, task models.Task) (models.Task, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -177,11 +240,37 @@ func UpdateTask(c *gin.Context) {
 		return
 	}
 
+	previous, err := fetchTaskByID(c, taskID)
+	if err != nil {
+		return
+	}
+
+	// A task with a checklist is completed exactly when all of its items are
+	set := updateData["$set"].(bson.M)
+	if _, ok := set["completed"]; ok && len(previous.Items) > 0 {
+		SendBadRequest(c, "Completion of a task with a checklist follows its items", nil)
+		return
+	}
+
+	set["updated_at"] = time.Now()
+	updateData["$inc"] = bson.M{"revision": 1}
+
+	// A task moved to another day goes at the end of that day
+	if date, ok := set["date"].(string); ok && taskDay(date) != taskDay(previous.Date) {
+		position, err := nextTaskPosition(c, previous.UserID, date)
+		if err != nil {
+			return
+		}
+		set["position"] = position
+	}
+
 	updatedTask, err := performTaskUpdate(c, taskID, updateData)
 	if err != nil {
 		return
 	}
 
+	afterTaskChange(&previous, updatedTask)
+
 	c.JSON(http.StatusOK, updatedTask)
 }
 
@@ -199,9 +288,11 @@ func validateAndGetTaskID(c *gin.Context) (primitive.ObjectID, error) {
 // parseUpdateData parses and validates the update data from the request body
 func parseUpdateData(c *gin.Context) (bson.M, error) {
 	var updateData struct {
-		Name      *string `json:"name"`
-		Completed *bool   `json:"completed"`
-		Date      *string `json:"date"`
+		Name      *string  `json:"name"`
+		Completed *bool    `json:"completed"`
+		Date      *string  `json:"date"`
+		Amount    *float64 `json:"amount"`
+		Note      *string  `json:"note"`
 	}
 
 	if err := c.ShouldBindJSON(&updateData); err != nil {
@@ -219,6 +310,12 @@ func parseUpdateData(c *gin.Context) (bson.M, error) {
 	if updateData.Date != nil {
 		updateFields["date"] = *updateData.Date
 	}
+	if updateData.Amount != nil {
+		updateFields["amount"] = *updateData.Amount
+	}
+	if updateData.Note != nil {
+		updateFields["note"] = strings.TrimSpace(*updateData.Note)
+	}
 
 	if len(updateFields) == 0 {
 		SendBadRequest(c, "No valid fields to update", nil)
@@ -236,7 +333,7 @@ func performTaskUpdate(c *gin.Context, taskID primitive.ObjectID, update bson.M)
 	var updatedTask models.Task
 	err := db.TaskColl.FindOneAndUpdate(
 		ctx,
-		bson.M{"_id": taskID},
+		bson.M{"_id": taskID, "deleted_at": nil},
 		update,
 		options.FindOneAndUpdate().SetReturnDocument(options.After),
 	).Decode(&updatedTask)
@@ -253,44 +350,234 @@ func performTaskUpdate(c *gin.Context, taskID primitive.ObjectID, update bson.M)
 	return updatedTask, nil
 }
 
-// DeleteTask deletes a task
-func DeleteTask(c *gin.Context) {
-	taskID, err := validateAndGetTaskID(c)
+// errTaskOrderMismatch is returned when a reorder request does not cover the day's tasks
+var errTaskOrderMismatch = errors.New("task_ids must list every task of the day exactly once")
+
+// errTaskOrderChanged is returned when the day's tasks change during a reorder
+var errTaskOrderChanged = errors.New("tasks changed while reordering, please retry")
+
+// ReorderTasks rewrites the positions of a user's tasks for a day all at once.
+// The body must list every task of that day exactly once.
+func ReorderTasks(c *gin.Context) {
+	var body struct {
+		UserID  string   `json:"user_id"`
+		Date    string   `json:"date"`
+		TaskIDs []string `json:"task_ids"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		SendBadRequest(c, "Invalid request body", err)
+		return
+	}
+
+	userID, err := primitive.ObjectIDFromHex(body.UserID)
+	if err != nil {
+		SendBadRequest(c, "Invalid user ID", err)
+		return
+	}
+
+	day, err := time.ParseInLocation(dateLayout, body.Date, time.Local)
 	if err != nil {
+		SendBadRequest(c, "Date must be in YYYY-MM-DD format", err)
 		return
 	}
 
-	if err := performTaskDeletion(c, taskID); err != nil {
+	taskIDs := make([]primitive.ObjectID, 0, len(body.TaskIDs))
+	for _, id := range body.TaskIDs {
+		objectID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			SendBadRequest(c, "Invalid task ID", err)
+			return
+		}
+		taskIDs = append(taskIDs, objectID)
+	}
+
+	filter := bson.M{"user_id": userID, "date": dayFilter(day), "deleted_at": nil}
+	guard := fmt.Sprintf("task-order:%s:%s", userID.Hex(), day.Format(dateLayout))
+	if err := rewriteTaskPositions(filter, guard, taskIDs); err != nil {
+		if errors.Is(err, errTaskOrderMismatch) {
+			SendBadRequest(c, err.Error(), nil)
+			return
+		}
+		if errors.Is(err, errTaskOrderChanged) {
+			SendError(c, http.StatusConflict, "Tasks changed while reordering, please retry", nil)
+			return
+		}
+		SendInternalError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Task deleted successfully"})
+	tasks, err := fetchTasksWithFilter(c, filter)
+	if err != nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, tasks)
 }
 
-// performTaskDeletion executes the task deletion in the database
-func performTaskDeletion(c *gin.Context, taskID primitive.ObjectID) error {
+// rewriteTaskPositions assigns positions in the order of taskIDs to the tasks
+// matched by filter. On a replica set the check and the writes run in one
+// transaction, so a concurrent add or delete cannot leave the day half
+// reordered. A standalone server has no transactions: there the day is guarded
+// against other reorders and the writes are undone if a task left it meanwhile.
+func rewriteTaskPositions(filter bson.M, guard string, taskIDs []primitive.ObjectID) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	result, err := db.TaskColl.DeleteOne(ctx, bson.M{"_id": taskID})
+	session, err := db.Client.StartSession()
 	if err != nil {
-		SendInternalError(c, err)
 		return err
 	}
+	defer session.EndSession(ctx)
 
-	if result.DeletedCount == 0 {
-		SendNotFound(c, "Task not found")
-		return fmt.Errorf("task not found")
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		_, err := writeTaskPositions(sessCtx, filter, taskIDs)
+		return nil, err
+	})
+	if !transactionsUnsupported(err) {
+		return err
 	}
+	return rewriteTaskPositionsGuarded(ctx, filter, guard, taskIDs)
+}
 
-	return nil
+// rewriteTaskPositionsGuarded rewrites positions without a transaction. A
+// guard document keeps other reorders of the day out, and if a task was
+// deleted or moved meanwhile the previous positions are written back.
+func rewriteTaskPositionsGuarded(ctx context.Context, filter bson.M, guard string, taskIDs []primitive.ObjectID) error {
+	now := time.Now()
+	_, err := db.LeaseColl.UpdateOne(ctx,
+		bson.M{"_id": guard, "expires_at": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"acquired_at": now, "expires_at": now.Add(time.Minute)}},
+		options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return errTaskOrderChanged
+	}
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if _, err := db.LeaseColl.DeleteOne(context.Background(), bson.M{"_id": guard}); err != nil {
+			log.Printf("Error releasing reorder guard %s: %v", guard, err)
+		}
+	}()
+
+	previous, err := writeTaskPositions(ctx, filter, taskIDs)
+	if !errors.Is(err, errTaskOrderChanged) {
+		return err
+	}
+	if _, undoErr := db.TaskColl.BulkWrite(ctx, positionWrites(filter, previous)); undoErr != nil {
+		return undoErr
+	}
+	return err
 }
 
-// dateTaskInfo represents the completion status of tasks for a specific date
-type dateTaskInfo struct {
-	total     int
-	completed int
-	frozen    bool
+// writeTaskPositions checks that taskIDs lists every task matched by filter
+// exactly once and writes their new positions. It returns the positions the
+// tasks had before, and errTaskOrderChanged if a task no longer matched filter
+// when its position was written.
+func writeTaskPositions(ctx context.Context, filter bson.M, taskIDs []primitive.ObjectID) (map[primitive.ObjectID]int, error) {
+	cursor, err := db.TaskColl.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1, "position": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var existing []models.Task
+	if err := cursor.All(ctx, &existing); err != nil {
+		return nil, err
+	}
+
+	previous := make(map[primitive.ObjectID]int, len(existing))
+	for _, task := range existing {
+		previous[task.ID] = task.Position
+	}
+	if len(taskIDs) != len(previous) {
+		return nil, errTaskOrderMismatch
+	}
+
+	positions := make(map[primitive.ObjectID]int, len(taskIDs))
+	for position, taskID := range taskIDs {
+		if _, ok := previous[taskID]; !ok {
+			return nil, errTaskOrderMismatch
+		}
+		if _, ok := positions[taskID]; ok {
+			return nil, errTaskOrderMismatch
+		}
+		positions[taskID] = position
+	}
+	if len(positions) == 0 {
+		return previous, nil
+	}
+
+	result, err := db.TaskColl.BulkWrite(ctx, positionWrites(filter, positions))
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount != int64(len(positions)) {
+		return previous, errTaskOrderChanged
+	}
+	return previous, nil
+}
+
+// positionWrites builds the updates setting each task's position, each
+// conditioned on the task still matching filter
+func positionWrites(filter bson.M, positions map[primitive.ObjectID]int) []mongo.WriteModel {
+	writes := make([]mongo.WriteModel, 0, len(positions))
+	for taskID, position := range positions {
+		taskFilter := bson.M{"_id": taskID}
+		for key, value := range filter {
+			taskFilter[key] = value
+		}
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(taskFilter).
+			SetUpdate(bson.M{"$set": bson.M{"position": position}}))
+	}
+	return writes
+}
+
+// transactionsUnsupported reports whether err is a standalone server refusing
+// a transaction
+func transactionsUnsupported(err error) bool {
+	var commandErr mongo.CommandError
+	return errors.As(err, &commandErr) && commandErr.Code == 20 // IllegalOperation
+}
+
+// DeleteTask moves a task to the trash. It can be restored until the trash is purged.
+func DeleteTask(c *gin.Context) {
+	taskID, err := validateAndGetTaskID(c)
+	if err != nil {
+		return
+	}
+
+	deletedTask, err := performTaskDeletion(c, taskID)
+	if err != nil {
+		return
+	}
+
+	afterTaskChange(&deletedTask, deletedTask)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Task moved to trash"})
+}
+
+// performTaskDeletion soft-deletes the task by stamping deleted_at
+func performTaskDeletion(c *gin.Context, taskID primitive.ObjectID) (models.Task, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var deletedTask models.Task
+	err := db.TaskColl.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": taskID, "deleted_at": nil},
+		bson.M{"$set": bson.M{"deleted_at": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&deletedTask)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			SendNotFound(c, "Task not found")
+			return models.Task{}, err
+		}
+		SendInternalError(c, err)
+		return models.Task{}, err
+	}
+
+	return deletedTask, nil
 }
 
 // GetUserStreak returns the current streak of completed tasks for a user.
@@ -306,8 +593,13 @@ func GetUserStreak(c *gin.Context) {
 		return
 	}
 
-	dateTasks := groupTasksByDate(tasks)
-	streak := calculateStreak(dateTasks)
+	inactive, excuses, err := fetchInactiveHabits(c, userID)
+	if err != nil {
+		return
+	}
+
+	dateTasks := streaks.GroupByDate(streaks.ExcludeHabitTasks(tasks, inactive))
+	streak := streaks.Current(dateTasks, excuses, time.Now())
 
 	c.JSON(http.StatusOK, gin.H{
 		"streak":  streak,
@@ -326,12 +618,27 @@ func validateAndGetUserID(c *gin.Context) (primitive.ObjectID, error) {
 	return objectID, nil
 }
 
+// requireUserIDQuery validates the required user_id query parameter
+func requireUserIDQuery(c *gin.Context) (primitive.ObjectID, error) {
+	userID := c.Query("user_id")
+	if userID == "" {
+		SendBadRequest(c, "user_id is required", nil)
+		return primitive.NilObjectID, fmt.Errorf("user_id is required")
+	}
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		SendBadRequest(c, "Invalid user ID", err)
+		return primitive.NilObjectID, err
+	}
+	return objectID, nil
+}
+
 // fetchUserTasks retrieves all tasks for a given user
 func fetchUserTasks(c *gin.Context, userID primitive.ObjectID) ([]models.Task, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	cursor, err := db.TaskColl.Find(ctx, bson.M{"user_id": userID})
+	cursor, err := db.TaskColl.Find(ctx, bson.M{"user_id": userID, "deleted_at": nil})
 	if err != nil {
 		SendInternalError(c, err)
 		return nil, err
@@ -346,55 +653,35 @@ func fetchUserTasks(c *gin.Context, userID primitive.ObjectID) ([]models.Task, e
 	return tasks, nil
 }
 
-// groupTasksByDate organizes tasks by date and tracks their completion status
-func groupTasksByDate(tasks []models.Task) map[string]dateTaskInfo {
-	dateTasks := make(map[string]dateTaskInfo)
-
-	for _, task := range tasks {
-		dateStr := task.Date[:10] // Get YYYY-MM-DD format
-		info := dateTasks[dateStr]
-		info.total++
-
-		if strings.Contains(strings.ToLower(task.Name), "frozen") {
-			info.frozen = true
-		} else if task.Completed {
-			info.completed++
-		}
-		dateTasks[dateStr] = info
-	}
-
-	return dateTasks
-}
-
-// calculateStreak determines the current streak of completed tasks
-func calculateStreak(dateTasks map[string]dateTaskInfo) int {
-	streak := 0
-	currentDate := time.Now()
+// fetchInactiveHabits returns the IDs of a user's paused and archived habits and
+// the pauses that excuse days without tasks
+func fetchInactiveHabits(c *gin.Context, userID primitive.ObjectID) (map[primitive.ObjectID]bool, streaks.Excuses, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-	// Check today's tasks
-	dateStr := currentDate.Format(time.RFC3339)[:10]
-	if info := dateTasks[dateStr]; !info.frozen && info.completed == info.total {
-		streak++
+	filter := bson.M{
+		"user_id":    userID,
+		"deleted_at": nil,
+		"$or": bson.A{
+			bson.M{"status": bson.M{"$in": bson.A{models.HabitStatusPaused, models.HabitStatusArchived}}},
+			bson.M{"pauses.0": bson.M{"$exists": true}},
+		},
+	}
+	cursor, err := db.HabitColl.Find(ctx, filter)
+	if err != nil {
+		SendInternalError(c, err)
+		return nil, nil, err
 	}
+	defer cursor.Close(ctx)
 
-	// Check previous days
-	for {
-		currentDate = currentDate.AddDate(0, 0, -1)
-		dateStr := currentDate.Format(time.RFC3339)[:10]
-		info := dateTasks[dateStr]
-
-		// Break streak if no tasks or incomplete tasks
-		if info.total == 0 || (!info.frozen && info.completed < info.total) {
-			break
-		}
-
-		// Only count days where all tasks are completed
-		if !info.frozen && info.completed == info.total {
-			streak++
-		}
+	var habits []models.Habit
+	if err = cursor.All(ctx, &habits); err != nil {
+		SendInternalError(c, err)
+		return nil, nil, err
 	}
 
-	return streak
+	inactive, excuses := streaks.Inactive(habits)
+	return inactive, excuses, nil
 }
 
 // DeleteFrozenTasks deletes all frozen tasks for a specific date
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"habit-tracker/server/db"
+	"habit-tracker/server/models"
+	"habit-tracker/server/templates"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GetTemplatePacks returns the habit template catalog
+func GetTemplatePacks(c *gin.Context) {
+	c.JSON(http.StatusOK, templates.Packs())
+}
+
+// GetTemplatePack returns a single pack of the catalog
+func GetTemplatePack(c *gin.Context) {
+	pack, ok := templates.FindPack(c.Param("id"))
+	if !ok {
+		SendNotFound(c, "Template pack not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, pack)
+}
+
+// InstantiateTemplatePack creates a user's habits from a pack. Templates the
+// user already has a habit for (by name) are skipped, so a pack can be applied
+// again safely; in stacked packs the existing habit still anchors the chain.
+func InstantiateTemplatePack(c *gin.Context) {
+	pack, ok := templates.FindPack(c.Param("id"))
+	if !ok {
+		SendNotFound(c, "Template pack not found")
+		return
+	}
+
+	var body struct {
+		UserID    string `json:"user_id"`
+		StartDate string `json:"start_date"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		SendBadRequest(c, "Invalid request body", err)
+		return
+	}
+
+	userID, err := primitive.ObjectIDFromHex(body.UserID)
+	if err != nil {
+		SendBadRequest(c, "Invalid user ID", err)
+		return
+	}
+
+	if body.StartDate == "" {
+		body.StartDate = time.Now().Format(dateLayout)
+	}
+	if _, err := time.Parse(dateLayout, body.StartDate); err != nil {
+		SendBadRequest(c, "Start date must be in YYYY-MM-DD format", err)
+		return
+	}
+
+	if err := validateUserExists(c, userID); err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := db.HabitColl.Find(ctx, bson.M{"user_id": userID, "deleted_at": nil})
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	var existing []models.Habit
+	if err = cursor.All(ctx, &existing); err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	existingByName := make(map[string]models.Habit, len(existing))
+	for _, habit := range existing {
+		existingByName[strings.ToLower(habit.Name)] = habit
+	}
+
+	created := []models.Habit{}
+	skipped := []string{}
+	var previous *primitive.ObjectID
+	for _, template := range pack.Templates {
+		if habit, ok := existingByName[strings.ToLower(template.Name)]; ok {
+			skipped = append(skipped, template.Name)
+			if habit.Type == models.HabitTypeBuild {
+				previous = &habit.ID
+			}
+			continue
+		}
+
+		habit := newHabitFromTemplate(userID, template, body.StartDate)
+		if pack.Stacked && habit.Type == models.HabitTypeBuild {
+			habit.PredecessorID = previous
+		}
+
+		result, err := db.HabitColl.InsertOne(ctx, habit)
+		if err != nil {
+			SendInternalError(c, err)
+			return
+		}
+		habit.ID = result.InsertedID.(primitive.ObjectID)
+		created = append(created, habit)
+
+		if habit.Type == models.HabitTypeBuild {
+			habitID := habit.ID
+			previous = &habitID
+		}
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"pack_id": pack.ID,
+		"created": created,
+		"skipped": skipped,
+	})
+}
+
+// newHabitFromTemplate builds a habit from a catalog template with the same
+// defaults CreateHabit applies
+func newHabitFromTemplate(userID primitive.ObjectID, template templates.Template, startDate string) models.Habit {
+	habit := models.Habit{
+		UserID:     userID,
+		Name:       template.Name,
+		Type:       template.Type,
+		StartDate:  startDate,
+		Weekdays:   template.Weekdays,
+		Difficulty: template.Difficulty,
+		Target:     template.Target,
+		Unit:       template.Unit,
+		Category:   template.Category,
+		Status:     models.HabitStatusActive,
+		CreatedAt:  time.Now(),
+	}
+	if habit.Type == "" {
+		habit.Type = models.HabitTypeBuild
+	}
+	if habit.Difficulty == "" {
+		habit.Difficulty = models.DifficultyMedium
+	}
+	return habit
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"habit-tracker/server/config"
+	"habit-tracker/server/db"
+	"habit-tracker/server/models"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// trashFilter matches a user's deleted documents that have not been purged yet
+func trashFilter(c *gin.Context) (bson.M, error) {
+	userID, err := requireUserIDQuery(c)
+	if err != nil {
+		return nil, err
+	}
+	return bson.M{
+		"user_id":    userID,
+		"deleted_at": bson.M{"$gte": time.Now().Add(-config.TrashRetention())},
+	}, nil
+}
+
+// GetTaskTrash returns a user's deleted tasks that can still be restored
+func GetTaskTrash(c *gin.Context) {
+	filter, err := trashFilter(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "deleted_at", Value: -1}})
+	cursor, err := db.TaskColl.Find(ctx, filter, findOptions)
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var tasks []models.Task
+	if err = cursor.All(ctx, &tasks); err != nil {
+		SendInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"retention_days": int(config.TrashRetention().Hours() / 24),
+		"tasks":          tasks,
+	})
+}
+
+// GetHabitTrash returns a user's deleted habits that can still be restored
+func GetHabitTrash(c *gin.Context) {
+	filter, err := trashFilter(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "deleted_at", Value: -1}})
+	cursor, err := db.HabitColl.Find(ctx, filter, findOptions)
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var habits []models.Habit
+	if err = cursor.All(ctx, &habits); err != nil {
+		SendInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"retention_days": int(config.TrashRetention().Hours() / 24),
+		"habits":         habits,
+	})
+}
+
+// RestoreTask takes a task out of the trash and puts it at the end of its day
+func RestoreTask(c *gin.Context) {
+	taskID, err := validateAndGetTaskID(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var task models.Task
+	err = db.TaskColl.FindOne(ctx, bson.M{"_id": taskID, "deleted_at": bson.M{"$ne": nil}}).Decode(&task)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			SendNotFound(c, "Task not found in trash")
+			return
+		}
+		SendInternalError(c, err)
+		return
+	}
+
+	position, err := nextTaskPosition(c, task.UserID, task.Date)
+	if err != nil {
+		return
+	}
+
+	var restoredTask models.Task
+	err = db.TaskColl.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": taskID, "deleted_at": bson.M{"$ne": nil}},
+		bson.M{"$set": bson.M{"position": position, "updated_at": time.Now()}, "$inc": bson.M{"revision": 1}, "$unset": bson.M{"deleted_at": ""}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&restoredTask)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			SendNotFound(c, "Task not found in trash")
+			return
+		}
+		SendInternalError(c, err)
+		return
+	}
+
+	afterTaskChange(&task, restoredTask)
+
+	c.JSON(http.StatusOK, restoredTask)
+}
+
+// RestoreHabit takes a habit out of the trash
+func RestoreHabit(c *gin.Context) {
+	habitID, err := validateAndGetHabitID(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var restoredHabit models.Habit
+	err = db.HabitColl.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": habitID, "deleted_at": bson.M{"$ne": nil}},
+		bson.M{"$set": bson.M{"updated_at": time.Now()}, "$inc": bson.M{"revision": 1}, "$unset": bson.M{"deleted_at": ""}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&restoredHabit)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			SendNotFound(c, "Habit not found in trash")
+			return
+		}
+		SendInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, restoredHabit)
+}
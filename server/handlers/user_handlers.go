@@ -112,6 +112,12 @@ func parseAndValidateUser(c *gin.Context) (models.User, error) {
 
 	user.CreatedAt = time.Now()
 	user.Streak = 0
+	user.XP = 0
+	user.PointsSpent = 0
+
+	if err := validateUserSettings(c, user.Settings); err != nil {
+		return models.User{}, err
+	}
 
 	return user, nil
 }
@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"habit-tracker/server/db"
+	"habit-tracker/server/models"
+	"habit-tracker/server/webhooks"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetWebhooks returns a user's webhooks. Secrets are only shown when they are
+// generated, on creation or rotation.
+func GetWebhooks(c *gin.Context) {
+	userID, err := requireUserIDQuery(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := db.WebhookColl.Find(ctx, bson.M{"user_id": userID}, findOptions)
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var hooks []models.Webhook
+	if err = cursor.All(ctx, &hooks); err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+
+	c.JSON(http.StatusOK, hooks)
+}
+
+// CreateWebhook registers a webhook endpoint with a freshly generated secret
+func CreateWebhook(c *gin.Context) {
+	var webhook models.Webhook
+	if err := c.ShouldBindJSON(&webhook); err != nil {
+		SendBadRequest(c, "Invalid request body", err)
+		return
+	}
+
+	webhook.URL = strings.TrimSpace(webhook.URL)
+	if err := validateWebhookURL(c, webhook.URL); err != nil {
+		return
+	}
+	events, err := normalizeWebhookEvents(c, webhook.Events)
+	if err != nil {
+		return
+	}
+	webhook.Events = events
+
+	if err := validateUserExists(c, webhook.UserID); err != nil {
+		return
+	}
+
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	webhook.Secret = secret
+	webhook.Active = true
+	webhook.CreatedAt = time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := db.WebhookColl.InsertOne(ctx, webhook)
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	webhook.ID = result.InsertedID.(primitive.ObjectID)
+
+	c.JSON(http.StatusCreated, webhook)
+}
+
+// UpdateWebhook updates a webhook's URL, events or active flag
+func UpdateWebhook(c *gin.Context) {
+	webhookID, err := validateAndGetWebhookID(c)
+	if err != nil {
+		return
+	}
+
+	var updateData struct {
+		URL    *string   `json:"url"`
+		Events *[]string `json:"events"`
+		Active *bool     `json:"active"`
+	}
+	if err := c.ShouldBindJSON(&updateData); err != nil {
+		SendBadRequest(c, "Invalid request body", err)
+		return
+	}
+
+	updateFields := bson.M{}
+	if updateData.URL != nil {
+		webhookURL := strings.TrimSpace(*updateData.URL)
+		if err := validateWebhookURL(c, webhookURL); err != nil {
+			return
+		}
+		updateFields["url"] = webhookURL
+	}
+	if updateData.Events != nil {
+		events, err := normalizeWebhookEvents(c, *updateData.Events)
+		if err != nil {
+			return
+		}
+		updateFields["events"] = events
+	}
+	if updateData.Active != nil {
+		updateFields["active"] = *updateData.Active
+	}
+	if len(updateFields) == 0 {
+		SendBadRequest(c, "No valid fields to update", nil)
+		return
+	}
+
+	updateWebhook(c, webhookID, updateFields, false)
+}
+
+// RotateWebhookSecret replaces a webhook's signing secret and returns the new
+// one. Requests are signed with the new secret from then on, retries included.
+func RotateWebhookSecret(c *gin.Context) {
+	webhookID, err := validateAndGetWebhookID(c)
+	if err != nil {
+		return
+	}
+
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+
+	updateWebhook(c, webhookID, bson.M{"secret": secret}, true)
+}
+
+// updateWebhook applies $set fields to a webhook and returns it, with its
+// secret only if showSecret is set
+func updateWebhook(c *gin.Context, webhookID primitive.ObjectID, updateFields bson.M, showSecret bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var updatedWebhook models.Webhook
+	err := db.WebhookColl.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": webhookID},
+		bson.M{"$set": updateFields},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updatedWebhook)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			SendNotFound(c, "Webhook not found")
+			return
+		}
+		SendInternalError(c, err)
+		return
+	}
+	if !showSecret {
+		updatedWebhook.Secret = ""
+	}
+
+	c.JSON(http.StatusOK, updatedWebhook)
+}
+
+// DeleteWebhook deletes a webhook. Queued deliveries fail on their next attempt;
+// delivery logs are kept.
+func DeleteWebhook(c *gin.Context) {
+	webhookID, err := validateAndGetWebhookID(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := db.WebhookColl.DeleteOne(ctx, bson.M{"_id": webhookID})
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	if result.DeletedCount == 0 {
+		SendNotFound(c, "Webhook not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// GetWebhookDeliveries returns a page of a webhook's deliveries, most recent
+// first, with every attempt made
+func GetWebhookDeliveries(c *gin.Context) {
+	webhookID, err := validateAndGetWebhookID(c)
+	if err != nil {
+		return
+	}
+
+	limit, offset, err := parsePagination(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"webhook_id": webhookID}
+	if status := c.Query("status"); status != "" {
+		filter["status"] = status
+	}
+	total, err := db.DeliveryColl.CountDocuments(ctx, filter)
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64(offset)).
+		SetLimit(int64(limit))
+	cursor, err := db.DeliveryColl.Find(ctx, filter, findOptions)
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	deliveries := []models.WebhookDelivery{}
+	if err = cursor.All(ctx, &deliveries); err != nil {
+		SendInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"deliveries": deliveries,
+		"total":      total,
+		"limit":      limit,
+		"offset":     offset,
+	})
+}
+
+// TestWebhook sends a ping event to a webhook right away and returns the
+// delivery with the attempt's outcome. Test deliveries are logged but not retried.
+func TestWebhook(c *gin.Context) {
+	webhookID, err := validateAndGetWebhookID(c)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var webhook models.Webhook
+	if err := db.WebhookColl.FindOne(ctx, bson.M{"_id": webhookID}).Decode(&webhook); err != nil {
+		if err == mongo.ErrNoDocuments {
+			SendNotFound(c, "Webhook not found")
+			return
+		}
+		SendInternalError(c, err)
+		return
+	}
+
+	delivery, err := webhooks.NewDelivery(webhook, models.EventPing, gin.H{"webhook_id": webhook.ID.Hex()})
+	if err != nil {
+		SendInternalError(c, err)
+		return
+	}
+
+	attempt := webhooks.Send(ctx, webhook, delivery)
+	delivery.Attempts = append(delivery.Attempts, attempt)
+	delivery.Status = models.DeliveryFailed
+	if attempt.Error == "" {
+		delivery.Status = models.DeliveryDelivered
+		delivery.DeliveredAt = &attempt.At
+	}
+
+	if _, err := db.DeliveryColl.InsertOne(ctx, delivery); err != nil {
+		SendInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, delivery)
+}
+
+// validateAndGetWebhookID validates the webhook ID from the request
+func validateAndGetWebhookID(c *gin.Context) (primitive.ObjectID, error) {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		SendBadRequest(c, "Invalid webhook ID", err)
+		return primitive.NilObjectID, err
+	}
+	return objectID, nil
+}
+
+// validateWebhookURL checks that a webhook URL is an absolute http(s) URL
+// whose host resolves to public addresses only
+func validateWebhookURL(c *gin.Context, webhookURL string) error {
+	parsed, err := url.Parse(webhookURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		SendBadRequest(c, "Webhook URL must be an absolute http or https URL", err)
+		return fmt.Errorf("invalid webhook URL: %q", webhookURL)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := webhooks.CheckHost(ctx, parsed.Hostname()); err != nil {
+		if errors.Is(err, webhooks.ErrPrivateAddress) {
+			SendBadRequest(c, "Webhook URL must point to a public address", err)
+			return err
+		}
+		SendBadRequest(c, "Webhook URL host could not be resolved", err)
+		return err
+	}
+	return nil
+}
+
+// normalizeWebhookEvents checks that events are known and removes duplicates
+func normalizeWebhookEvents(c *gin.Context, events []string) ([]string, error) {
+	if len(events) == 0 {
+		SendBadRequest(c, "Subscribe to at least one event: "+strings.Join(webhooks.Events, ", "), nil)
+		return nil, fmt.Errorf("no webhook events")
+	}
+
+	seen := make(map[string]bool, len(events))
+	normalized := []string{}
+	for _, event := range events {
+		if !webhooks.IsEvent(event) {
+			SendBadRequest(c, "Unknown event "+event+"; expected one of "+strings.Join(webhooks.Events, ", "), nil)
+			return nil, fmt.Errorf("unknown webhook event: %s", event)
+		}
+		if !seen[event] {
+			seen[event] = true
+			normalized = append(normalized, event)
+		}
+	}
+	return normalized, nil
+}
+
+// publishEvent queues a webhook event. Failures are logged rather than failing
+// the request that triggered the event.
+func publishEvent(userID primitive.ObjectID, event string, data interface{}) {
+	if err := webhooks.Publish(userID, event, data); err != nil {
+		log.Printf("Error publishing %s for user %s: %v", event, userID.Hex(), err)
+	}
+}
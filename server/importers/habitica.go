@@ -0,0 +1,391 @@
+package importers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"habit-tracker/server/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// habiticaTask is the part of a Habitica task that is imported
+type habiticaTask struct {
+	ID            string             `json:"id"`
+	LegacyID      string             `json:"_id"`
+	Type          string             `json:"type"`
+	Text          string             `json:"text"`
+	Notes         string             `json:"notes"`
+	Priority      float64            `json:"priority"`
+	CreatedAt     habiticaTime       `json:"createdAt"`
+	StartDate     habiticaTime       `json:"startDate"`
+	Date          habiticaTime       `json:"date"`
+	DateCompleted habiticaTime       `json:"dateCompleted"`
+	Completed     bool               `json:"completed"`
+	Up            bool               `json:"up"`
+	Down          bool               `json:"down"`
+	Frequency     string             `json:"frequency"`
+	EveryX        int                `json:"everyX"`
+	Repeat        map[string]bool    `json:"repeat"`
+	Tags          []string           `json:"tags"`
+	Checklist     []habiticaItem     `json:"checklist"`
+	History       []habiticaEntry    `json:"history"`
+	Challenge     *habiticaChallenge `json:"challenge"`
+}
+
+// habiticaItem is a checklist item of a Habitica task
+type habiticaItem struct {
+	Text      string `json:"text"`
+	Completed bool   `json:"completed"`
+}
+
+// habiticaEntry is a history entry of a Habitica habit or daily. Older
+// exports only have the task value, not whether the day was completed.
+type habiticaEntry struct {
+	Date       habiticaTime `json:"date"`
+	Value      float64      `json:"value"`
+	Completed  *bool        `json:"completed"`
+	IsDue      *bool        `json:"isDue"`
+	ScoredUp   *int         `json:"scoredUp"`
+	ScoredDown *int         `json:"scoredDown"`
+}
+
+// habiticaChallenge tells whether a task belongs to a challenge
+type habiticaChallenge struct {
+	ID string `json:"id"`
+}
+
+// habiticaTime is a Habitica timestamp: milliseconds since the epoch, or an
+// ISO 8601 string
+type habiticaTime struct {
+	time.Time
+}
+
+// UnmarshalJSON accepts both timestamp forms, and null
+func (t *habiticaTime) UnmarshalJSON(data []byte) error {
+	var millis float64
+	if err := json.Unmarshal(data, &millis); err == nil {
+		t.Time = time.UnixMilli(int64(millis))
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil || text == "" {
+		return nil
+	}
+	parsed, err := time.Parse(time.RFC3339, text)
+	if err != nil {
+		return nil
+	}
+	t.Time = parsed
+	return nil
+}
+
+// habiticaWeekdays maps Habitica's repeat keys to weekdays
+var habiticaWeekdays = map[string]int{"su": 0, "m": 1, "t": 2, "w": 3, "th": 4, "f": 5, "s": 6}
+
+// ParseHabitica reads a Habitica data export (userdata.json) or the response
+// of its tasks API. Dailies become build habits with their history as tasks.
+// Habits with only a positive action become build habits, completed on the
+// days they were scored up; habits with only a negative action become avoid
+// habits, with a relapse on each day they were scored down. To-dos become
+// plain tasks. Days are taken in loc.
+func ParseHabitica(data []byte, loc *time.Location) (Plan, error) {
+	tasks, rewards, err := decodeHabitica(data)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	plan := Plan{Source: SourceHabitica}
+	day := func(t habiticaTime) string {
+		return t.In(loc).Format(dateLayout)
+	}
+
+	tagged, challenged := 0, 0
+	for i, task := range tasks {
+		task.Text = strings.TrimSpace(task.Text)
+		if task.Text == "" {
+			plan.unmapped(task.Type, "", "tasks without a name cannot be imported", 1)
+			continue
+		}
+		if len(task.Tags) > 0 {
+			tagged++
+		}
+		if task.Challenge != nil && task.Challenge.ID != "" {
+			challenged++
+		}
+		key := task.ID
+		if key == "" {
+			key = task.LegacyID
+		}
+		if key == "" {
+			key = fmt.Sprintf("task-%d", i)
+		}
+
+		switch task.Type {
+		case "daily":
+			importHabiticaDaily(&plan, key, task, day)
+		case "habit":
+			importHabiticaHabit(&plan, key, task, day)
+		case "todo":
+			importHabiticaTodo(&plan, task, day)
+		default:
+			plan.unmapped(task.Type, task.Text, "unknown task type", 1)
+		}
+	}
+
+	plan.unmapped("reward", "", "rewards are bought with gold, which has no equivalent; create them again with a points cost", rewards)
+	plan.unmapped("tag", "", "tags are not imported", tagged)
+	plan.unmapped("challenge", "", "tasks were imported as your own; challenge membership is not", challenged)
+
+	return plan, nil
+}
+
+// decodeHabitica finds the tasks of an export and counts its rewards
+func decodeHabitica(data []byte) ([]habiticaTask, int, error) {
+	var export struct {
+		Tasks *struct {
+			Habits  []habiticaTask `json:"habits"`
+			Dailys  []habiticaTask `json:"dailys"`
+			Todos   []habiticaTask `json:"todos"`
+			Rewards []habiticaTask `json:"rewards"`
+		} `json:"tasks"`
+		Data []habiticaTask `json:"data"`
+	}
+	if err := json.Unmarshal(data, &export); err != nil {
+		var list []habiticaTask
+		if listErr := json.Unmarshal(data, &list); listErr != nil {
+			return nil, 0, fmt.Errorf("invalid Habitica export: %w", err)
+		}
+		export.Data = list
+	}
+
+	var tasks []habiticaTask
+	rewards := 0
+	if export.Tasks != nil {
+		for _, group := range []struct {
+			kind  string
+			tasks []habiticaTask
+		}{{"habit", export.Tasks.Habits}, {"daily", export.Tasks.Dailys}, {"todo", export.Tasks.Todos}} {
+			for _, task := range group.tasks {
+				task.Type = group.kind
+				tasks = append(tasks, task)
+			}
+		}
+		rewards = len(export.Tasks.Rewards)
+	}
+	for _, task := range export.Data {
+		if task.Type == "reward" {
+			rewards++
+			continue
+		}
+		tasks = append(tasks, task)
+	}
+
+	if len(tasks) == 0 && rewards == 0 {
+		return nil, 0, fmt.Errorf("no Habitica tasks found in the export")
+	}
+	return tasks, rewards, nil
+}
+
+// importHabiticaDaily plans a daily as a build habit and its due days as tasks
+func importHabiticaDaily(plan *Plan, key string, task habiticaTask, day func(habiticaTime) string) {
+	habit := newHabit(task.Text, models.HabitTypeBuild, habiticaStart(task, day))
+	habit.Difficulty = habiticaDifficulty(task.Priority)
+
+	switch task.Frequency {
+	case "weekly":
+		for name, on := range task.Repeat {
+			if weekday, ok := habiticaWeekdays[name]; ok && on {
+				habit.Weekdays = append(habit.Weekdays, weekday)
+			}
+		}
+		sort.Ints(habit.Weekdays)
+		if len(habit.Weekdays) == 7 {
+			habit.Weekdays = nil
+		}
+		if task.EveryX > 1 {
+			plan.unmapped("daily", task.Text, fmt.Sprintf("repeats every %d weeks; imported as every week", task.EveryX), 1)
+		}
+	case "daily", "":
+		if task.EveryX > 1 {
+			plan.unmapped("daily", task.Text, fmt.Sprintf("repeats every %d days; imported as daily", task.EveryX), 1)
+		}
+	default:
+		plan.unmapped("daily", task.Text, fmt.Sprintf("%s repeats are not supported; imported as daily", task.Frequency), 1)
+	}
+	if len(task.Checklist) > 0 {
+		plan.unmapped("daily", task.Text, "checklists of dailies are not imported", len(task.Checklist))
+	}
+	plan.Habits = append(plan.Habits, PlannedHabit{Key: key, Habit: habit})
+
+	// Without a completed flag, a day counts as completed when the task's
+	// value went up, which is how Habitica scores a checked daily
+	completed := map[string]bool{}
+	previous := 0.0
+	for i, entry := range sortedHistory(task.History) {
+		if entry.Date.IsZero() || entry.IsDue != nil && !*entry.IsDue {
+			previous = entry.Value
+			continue
+		}
+		done := entry.Value > previous && i > 0
+		if entry.Completed != nil {
+			done = *entry.Completed
+		}
+		previous = entry.Value
+		date := day(entry.Date)
+		completed[date] = completed[date] || done
+	}
+	for _, date := range sortedKeys(completed) {
+		plan.Tasks = append(plan.Tasks, PlannedTask{
+			HabitKey: key,
+			Task:     models.Task{Name: task.Text, Date: date, Completed: completed[date], CreatedAt: parseDay(date)},
+		})
+	}
+}
+
+// importHabiticaHabit plans a Habitica habit as a build or avoid habit
+func importHabiticaHabit(plan *Plan, key string, task habiticaTask, day func(habiticaTime) string) {
+	habitType := models.HabitTypeBuild
+	if task.Down && !task.Up {
+		habitType = models.HabitTypeAvoid
+	}
+	habit := newHabit(task.Text, habitType, habiticaStart(task, day))
+	habit.Difficulty = habiticaDifficulty(task.Priority)
+	plan.Habits = append(plan.Habits, PlannedHabit{Key: key, Habit: habit})
+
+	if !task.Up && !task.Down {
+		plan.unmapped("habit", task.Text, "has neither a positive nor a negative action; imported without history", 1)
+		return
+	}
+
+	ups, downs := map[string]bool{}, map[string]bool{}
+	previous := 0.0
+	for i, entry := range sortedHistory(task.History) {
+		if entry.Date.IsZero() {
+			continue
+		}
+		date := day(entry.Date)
+		up, down := entry.Value > previous && i > 0, entry.Value < previous && i > 0
+		if entry.ScoredUp != nil || entry.ScoredDown != nil {
+			up = entry.ScoredUp != nil && *entry.ScoredUp > 0
+			down = entry.ScoredDown != nil && *entry.ScoredDown > 0
+		}
+		previous = entry.Value
+		ups[date] = ups[date] || up
+		downs[date] = downs[date] || down
+	}
+
+	if habitType == models.HabitTypeAvoid {
+		for _, date := range sortedKeys(downs) {
+			if downs[date] {
+				plan.Relapses = append(plan.Relapses, PlannedRelapse{HabitKey: key, Date: date, Note: "Imported from Habitica"})
+			}
+		}
+		return
+	}
+
+	for _, date := range sortedKeys(ups) {
+		if ups[date] {
+			plan.Tasks = append(plan.Tasks, PlannedTask{
+				HabitKey: key,
+				Task:     models.Task{Name: task.Text, Date: date, Completed: true, CreatedAt: parseDay(date)},
+			})
+		}
+	}
+	negative := 0
+	for _, down := range downs {
+		if down {
+			negative++
+		}
+	}
+	if task.Down {
+		plan.unmapped("habit", task.Text, "negative scores of habits with both actions are not imported", negative)
+	}
+}
+
+// importHabiticaTodo plans a to-do as a task on its due date, or the day it
+// was completed or created when it has none
+func importHabiticaTodo(plan *Plan, task habiticaTask, day func(habiticaTime) string) {
+	date := task.CreatedAt
+	switch {
+	case !task.Date.IsZero():
+		date = task.Date
+	case task.Completed && !task.DateCompleted.IsZero():
+		date = task.DateCompleted
+	}
+	if date.IsZero() {
+		date = habiticaTime{time.Now()}
+	}
+
+	imported := models.Task{
+		Name:      task.Text,
+		Date:      day(date),
+		Completed: task.Completed,
+		Note:      strings.TrimSpace(task.Notes),
+		CreatedAt: task.CreatedAt.Time,
+	}
+	for _, item := range task.Checklist {
+		if name := strings.TrimSpace(item.Text); name != "" {
+			imported.Items = append(imported.Items, models.ChecklistItem{ID: primitive.NewObjectID(), Name: name, Completed: item.Completed})
+		}
+	}
+	plan.Tasks = append(plan.Tasks, PlannedTask{Task: imported})
+}
+
+// habiticaStart returns the day a Habitica task started
+func habiticaStart(task habiticaTask, day func(habiticaTime) string) string {
+	start := task.StartDate
+	if start.IsZero() {
+		start = task.CreatedAt
+	}
+	for _, entry := range task.History {
+		if !entry.Date.IsZero() && (start.IsZero() || entry.Date.Before(start.Time)) {
+			start = entry.Date
+		}
+	}
+	if start.IsZero() {
+		return time.Now().Format(dateLayout)
+	}
+	return day(start)
+}
+
+// habiticaDifficulty maps a Habitica priority to a difficulty. Trivial tasks
+// (0.1) count as easy.
+func habiticaDifficulty(priority float64) string {
+	switch {
+	case priority >= 2:
+		return models.DifficultyHard
+	case priority >= 1.5 || priority == 0:
+		return models.DifficultyMedium
+	default:
+		return models.DifficultyEasy
+	}
+}
+
+// sortedHistory returns history entries oldest first
+func sortedHistory(history []habiticaEntry) []habiticaEntry {
+	sorted := append([]habiticaEntry(nil), history...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Date.Before(sorted[j].Date.Time)
+	})
+	return sorted
+}
+
+// sortedKeys returns the keys of a map in ascending order
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// parseDay returns the start of a YYYY-MM-DD day in UTC
+func parseDay(date string) time.Time {
+	day, _ := time.Parse(dateLayout, date)
+	return day
+}
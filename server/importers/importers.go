@@ -0,0 +1,388 @@
+// Package importers reads the exports of other habit tracking apps and maps
+// their habits and check-in history onto ours. Parsing yields a Plan, which
+// can be summarized for a preview before it is applied. Anything that has no
+// equivalent here is listed as unmapped rather than silently dropped.
+package importers
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"habit-tracker/server/db"
+	"habit-tracker/server/models"
+	"habit-tracker/server/taskcsv"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// dateLayout is the YYYY-MM-DD format used for task dates
+const dateLayout = "2006-01-02"
+
+// Sources
+const (
+	SourceLoop     = "loop"
+	SourceHabitica = "habitica"
+)
+
+// PlannedHabit is a habit to import. Key is the habit's identity in the
+// source, which planned tasks and relapses refer to.
+type PlannedHabit struct {
+	Key   string
+	Habit models.Habit
+}
+
+// PlannedTask is a task to import, belonging to the habit with HabitKey or to
+// no habit when it is empty
+type PlannedTask struct {
+	HabitKey string
+	Task     models.Task
+}
+
+// PlannedRelapse is a relapse of an avoid habit to import
+type PlannedRelapse struct {
+	HabitKey string
+	Date     string
+	Note     string
+}
+
+// Unmapped describes source data that could not be imported, or only
+// partially. Count is how many records it concerns.
+type Unmapped struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name,omitempty"`
+	Reason string `json:"reason"`
+	Count  int    `json:"count"`
+}
+
+// Plan is the outcome of parsing an export
+type Plan struct {
+	Source   string
+	Habits   []PlannedHabit
+	Tasks    []PlannedTask
+	Relapses []PlannedRelapse
+	Unmapped []Unmapped
+}
+
+// unmapped records source data that could not be imported
+func (p *Plan) unmapped(kind, name, reason string, count int) {
+	if count > 0 {
+		p.Unmapped = append(p.Unmapped, Unmapped{Kind: kind, Name: name, Reason: reason, Count: count})
+	}
+}
+
+// HabitPreview summarizes a planned habit and its history
+type HabitPreview struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Weekdays  []int  `json:"weekdays,omitempty"`
+	Status    string `json:"status"`
+	StartDate string `json:"start_date"`
+	Tasks     int    `json:"tasks"`
+	Completed int    `json:"completed"`
+	Relapses  int    `json:"relapses"`
+}
+
+// Preview summarizes what applying a plan would import
+type Preview struct {
+	Source    string         `json:"source"`
+	Habits    []HabitPreview `json:"habits"`
+	Tasks     int            `json:"tasks"`
+	Completed int            `json:"completed"`
+	Relapses  int            `json:"relapses"`
+	FirstDate string         `json:"first_date,omitempty"`
+	LastDate  string         `json:"last_date,omitempty"`
+	Unmapped  []Unmapped     `json:"unmapped"`
+}
+
+// Preview summarizes the plan
+func (p Plan) Preview() Preview {
+	preview := Preview{Source: p.Source, Habits: []HabitPreview{}, Unmapped: p.Unmapped}
+	if preview.Unmapped == nil {
+		preview.Unmapped = []Unmapped{}
+	}
+
+	byKey := make(map[string]int, len(p.Habits))
+	for _, planned := range p.Habits {
+		byKey[planned.Key] = len(preview.Habits)
+		preview.Habits = append(preview.Habits, HabitPreview{
+			Name:      planned.Habit.Name,
+			Type:      planned.Habit.Type,
+			Weekdays:  planned.Habit.Weekdays,
+			Status:    planned.Habit.Status,
+			StartDate: planned.Habit.StartDate,
+		})
+	}
+
+	for _, planned := range p.Tasks {
+		preview.Tasks++
+		if planned.Task.Completed {
+			preview.Completed++
+		}
+		if i, ok := byKey[planned.HabitKey]; ok && planned.HabitKey != "" {
+			preview.Habits[i].Tasks++
+			if planned.Task.Completed {
+				preview.Habits[i].Completed++
+			}
+		}
+		if date := planned.Task.Date; preview.FirstDate == "" || date < preview.FirstDate {
+			preview.FirstDate = date
+		}
+		if date := planned.Task.Date; date > preview.LastDate {
+			preview.LastDate = date
+		}
+	}
+	for _, planned := range p.Relapses {
+		preview.Relapses++
+		if i, ok := byKey[planned.HabitKey]; ok {
+			preview.Habits[i].Relapses++
+		}
+	}
+
+	return preview
+}
+
+// Result counts what applying a plan wrote. Habits whose name and type match
+// an existing habit of the user are merged into it rather than created, and
+// tasks and relapses already present are skipped, so an import can safely be
+// rerun.
+type Result struct {
+	Source            string     `json:"source"`
+	HabitsCreated     int        `json:"habits_created"`
+	HabitsMerged      int        `json:"habits_merged"`
+	TasksCreated      int        `json:"tasks_created"`
+	TasksDuplicate    int        `json:"tasks_duplicate"`
+	RelapsesCreated   int        `json:"relapses_created"`
+	RelapsesDuplicate int        `json:"relapses_duplicate"`
+	Unmapped          []Unmapped `json:"unmapped"`
+}
+
+// Apply imports a plan into a user's account. If writing fails partway,
+// whatever the import had written is removed again.
+func Apply(userID primitive.ObjectID, plan Plan) (Result, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	result := Result{Source: plan.Source, Unmapped: plan.Unmapped}
+	if result.Unmapped == nil {
+		result.Unmapped = []Unmapped{}
+	}
+
+	// Merge into existing habits by name, ignoring case, and type
+	cursor, err := db.HabitColl.Find(ctx, bson.M{"user_id": userID, "deleted_at": nil})
+	if err != nil {
+		return Result{}, err
+	}
+	var existing []models.Habit
+	if err := cursor.All(ctx, &existing); err != nil {
+		return Result{}, err
+	}
+	byKey := make(map[string]models.Habit, len(existing))
+	for _, habit := range existing {
+		byKey[habitKey(habit)] = habit
+	}
+
+	habitIDs := make(map[string]primitive.ObjectID, len(plan.Habits))
+	var habits []interface{}
+	var written writes
+	for _, planned := range plan.Habits {
+		if habit, ok := byKey[habitKey(planned.Habit)]; ok {
+			habitIDs[planned.Key] = habit.ID
+			result.HabitsMerged++
+			continue
+		}
+		habit := planned.Habit
+		habit.ID = primitive.NewObjectID()
+		habit.UserID = userID
+		byKey[habitKey(habit)] = habit
+		habitIDs[planned.Key] = habit.ID
+		habits = append(habits, habit)
+		written.add(db.HabitColl, habit.ID)
+	}
+
+	tasks, err := newTasks(ctx, userID, plan.Tasks, habitIDs)
+	if err != nil {
+		return Result{}, err
+	}
+	documents := make([]interface{}, 0, len(tasks))
+	for _, task := range tasks {
+		documents = append(documents, task)
+		written.add(db.TaskColl, task.ID)
+	}
+
+	relapses, err := newRelapses(ctx, userID, plan.Relapses, habitIDs)
+	if err != nil {
+		return Result{}, err
+	}
+	relapseDocuments := make([]interface{}, 0, len(relapses))
+	for _, relapse := range relapses {
+		relapseDocuments = append(relapseDocuments, relapse)
+		written.add(db.RelapseColl, relapse.ID)
+	}
+
+	for _, batch := range []struct {
+		coll *mongo.Collection
+		docs []interface{}
+	}{
+		{db.HabitColl, habits},
+		{db.TaskColl, documents},
+		{db.RelapseColl, relapseDocuments},
+	} {
+		if len(batch.docs) == 0 {
+			continue
+		}
+		if _, err := batch.coll.InsertMany(ctx, batch.docs); err != nil {
+			written.rollback(ctx)
+			return Result{}, err
+		}
+	}
+
+	result.HabitsCreated = len(habits)
+	result.TasksCreated = len(tasks)
+	result.TasksDuplicate = len(plan.Tasks) - len(tasks)
+	result.RelapsesCreated = len(relapses)
+	result.RelapsesDuplicate = len(plan.Relapses) - len(relapses)
+	return result, nil
+}
+
+// writes lists the IDs of the records an import inserts, per collection
+type writes map[*mongo.Collection][]primitive.ObjectID
+
+// add records a document about to be inserted
+func (w *writes) add(coll *mongo.Collection, id primitive.ObjectID) {
+	if *w == nil {
+		*w = writes{}
+	}
+	(*w)[coll] = append((*w)[coll], id)
+}
+
+// rollback removes the records of a failed import. A failed InsertMany may
+// have written part of its batch, so every planned ID is removed.
+func (w writes) rollback(ctx context.Context) {
+	for coll, ids := range w {
+		if _, err := coll.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}}); err != nil {
+			log.Printf("Error rolling back import from %s: %v", coll.Name(), err)
+		}
+	}
+}
+
+// habitKey identifies a habit by its name, ignoring case, and its type
+func habitKey(habit models.Habit) string {
+	return strings.ToLower(strings.TrimSpace(habit.Name)) + "|" + habit.Type
+}
+
+// newTasks builds the planned tasks that do not match an existing task by
+// name and day. Each goes at the end of its day.
+func newTasks(ctx context.Context, userID primitive.ObjectID, planned []PlannedTask, habitIDs map[string]primitive.ObjectID) ([]models.Task, error) {
+	if len(planned) == 0 {
+		return nil, nil
+	}
+
+	dates := make([]string, 0, len(planned))
+	for _, p := range planned {
+		dates = append(dates, p.Task.Date)
+	}
+	sort.Strings(dates)
+	end, _ := time.Parse(dateLayout, dates[len(dates)-1])
+
+	cursor, err := db.TaskColl.Find(ctx, bson.M{
+		"user_id":    userID,
+		"deleted_at": nil,
+		"date":       bson.M{"$gte": dates[0], "$lt": end.AddDate(0, 0, 1).Format(dateLayout)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var existing []models.Task
+	if err := cursor.All(ctx, &existing); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(existing))
+	positions := make(map[string]int)
+	for _, task := range existing {
+		seen[taskcsv.Key(task.Name, task.Date)] = true
+		day := task.Date[:min(len(task.Date), len(dateLayout))]
+		positions[day] = max(positions[day], task.Position+1)
+	}
+
+	tasks := []models.Task{}
+	for _, p := range planned {
+		key := taskcsv.Key(p.Task.Name, p.Task.Date)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		task := p.Task
+		task.ID = primitive.NewObjectID()
+		task.UserID = userID
+		if id, ok := habitIDs[p.HabitKey]; ok && p.HabitKey != "" {
+			task.HabitID = &id
+		}
+		task.Position = positions[task.Date]
+		positions[task.Date]++
+		if task.CreatedAt.IsZero() {
+			task.CreatedAt = time.Now()
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
+
+// newRelapses builds the planned relapses not already logged on their day
+func newRelapses(ctx context.Context, userID primitive.ObjectID, planned []PlannedRelapse, habitIDs map[string]primitive.ObjectID) ([]models.Relapse, error) {
+	if len(planned) == 0 {
+		return nil, nil
+	}
+
+	cursor, err := db.RelapseColl.Find(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	var existing []models.Relapse
+	if err := cursor.All(ctx, &existing); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(existing))
+	for _, relapse := range existing {
+		seen[relapse.HabitID.Hex()+"|"+relapse.Date] = true
+	}
+
+	relapses := []models.Relapse{}
+	for _, p := range planned {
+		habitID, ok := habitIDs[p.HabitKey]
+		if !ok || seen[habitID.Hex()+"|"+p.Date] {
+			continue
+		}
+		seen[habitID.Hex()+"|"+p.Date] = true
+
+		occurredAt, _ := time.Parse(dateLayout, p.Date)
+		relapses = append(relapses, models.Relapse{
+			ID:         primitive.NewObjectID(),
+			HabitID:    habitID,
+			UserID:     userID,
+			Date:       p.Date,
+			Note:       p.Note,
+			OccurredAt: occurredAt,
+			CreatedAt:  time.Now(),
+		})
+	}
+	return relapses, nil
+}
+
+// newHabit returns a habit with the defaults of a habit created through the API
+func newHabit(name, habitType, startDate string) models.Habit {
+	return models.Habit{
+		Name:       name,
+		Type:       habitType,
+		StartDate:  startDate,
+		Difficulty: models.DifficultyMedium,
+		Status:     models.HabitStatusActive,
+		CreatedAt:  time.Now(),
+	}
+}
@@ -0,0 +1,344 @@
+package importers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"habit-tracker/server/models"
+)
+
+// Loop Habit Tracker checkmark values. Numerical habits store the amount
+// multiplied by loopNumericScale instead.
+const (
+	loopUnknown   = -1
+	loopNo        = 0
+	loopYesAuto   = 1
+	loopYesManual = 2
+	loopSkip      = 3
+
+	loopNumericScale = 1000
+)
+
+// Limits on reading an export, so a small zip cannot expand into a huge one
+const (
+	maxZipEntries    = 10000     // files in the archive
+	maxZipFileBytes  = 50 << 20  // uncompressed size of a single file read
+	maxZipTotalBytes = 100 << 20 // uncompressed size of all files read
+)
+
+// loopHabitFolder matches the per-habit folders of a Loop export, such as
+// "001 Meditate"
+var loopHabitFolder = regexp.MustCompile(`^\d+ (.+)$`)
+
+// loopHabit is a row of Loop's Habits.csv
+type loopHabit struct {
+	name        string
+	description string
+	numerical   bool
+	atMost      bool
+	target      float64
+	unit        string
+	numerator   int
+	denominator int
+	archived    bool
+}
+
+// ParseLoop reads a Loop Habit Tracker export: the zip file made by "Export as
+// CSV", or just its Checkmarks.csv. Every day with a known checkmark becomes a
+// task of the habit, completed or not. Loop only allows numbers of times per
+// period rather than weekdays, so such habits are imported as daily.
+func ParseLoop(data []byte) (Plan, error) {
+	plan := Plan{Source: SourceLoop}
+
+	habits := map[string]loopHabit{}
+	var checkmarks []byte
+	perHabit := map[string][]byte{}
+
+	if bytes.HasPrefix(data, []byte("PK")) {
+		archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return Plan{}, fmt.Errorf("invalid zip file: %w", err)
+		}
+		if len(archive.File) > maxZipEntries {
+			return Plan{}, fmt.Errorf("zip file has more than %d entries", maxZipEntries)
+		}
+		budget := int64(maxZipTotalBytes)
+		for _, file := range archive.File {
+			dir, name := path.Split(file.Name)
+			dir = strings.TrimSuffix(dir, "/")
+			if name != "Habits.csv" && name != "Checkmarks.csv" {
+				continue
+			}
+			folder := loopHabitFolder.FindStringSubmatch(path.Base(dir))
+			if dir != "" && (name != "Checkmarks.csv" || folder == nil) {
+				continue
+			}
+
+			content, err := readZipFile(file, &budget)
+			if err != nil {
+				return Plan{}, err
+			}
+			switch {
+			case dir == "" && name == "Habits.csv":
+				if habits, err = parseLoopHabits(content); err != nil {
+					return Plan{}, err
+				}
+			case dir == "":
+				checkmarks = content
+			default:
+				perHabit[folder[1]] = content
+			}
+		}
+	} else {
+		checkmarks = data
+	}
+
+	// columns maps each habit name to its checkmarks by date
+	columns := map[string]map[string]int{}
+	var err error
+	switch {
+	case checkmarks != nil:
+		if columns, err = parseLoopCheckmarks(checkmarks); err != nil {
+			return Plan{}, err
+		}
+	case len(perHabit) > 0:
+		for name, content := range perHabit {
+			column, err := parseLoopHabitCheckmarks(content)
+			if err != nil {
+				return Plan{}, fmt.Errorf("%s: %w", name, err)
+			}
+			columns[name] = column
+		}
+	default:
+		return Plan{}, fmt.Errorf("no Checkmarks.csv found in the export")
+	}
+
+	names := make([]string, 0, len(columns))
+	for name := range columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		info, known := habits[name]
+		dates := make([]string, 0, len(columns[name]))
+		for date := range columns[name] {
+			dates = append(dates, date)
+		}
+		sort.Strings(dates)
+
+		habit := newHabit(name, models.HabitTypeBuild, time.Now().Format(dateLayout))
+		skipped := 0
+		for _, date := range dates {
+			value := columns[name][date]
+			if value == loopUnknown {
+				continue
+			}
+			if value == loopSkip && !info.numerical {
+				skipped++
+				continue
+			}
+			if habit.StartDate > date {
+				habit.StartDate = date
+			}
+
+			task := models.Task{Name: name, Date: date, Completed: value == loopYesAuto || value == loopYesManual}
+			if info.numerical {
+				amount := float64(value) / loopNumericScale
+				task.Amount = &amount
+				task.Completed = amount > 0 && (info.target == 0 || amount >= info.target)
+			}
+			task.CreatedAt = parseDay(date)
+			plan.Tasks = append(plan.Tasks, PlannedTask{HabitKey: name, Task: task})
+		}
+		plan.unmapped("checkmark", name, "skipped days have no equivalent and were not imported", skipped)
+
+		if known {
+			if info.numerical {
+				habit.Target = info.target
+				habit.Unit = info.unit
+				if info.atMost {
+					habit.Target = 0
+					plan.unmapped("habit", name, "\"at most\" targets are not supported; days with any amount count as completed", 1)
+				}
+			}
+			if info.numerator > 0 && info.denominator > 1 {
+				plan.unmapped("habit", name, fmt.Sprintf("frequency of %d times every %d days imported as a daily schedule", info.numerator, info.denominator), 1)
+			}
+			if info.description != "" {
+				plan.unmapped("habit", name, "habits have no description; it was not imported", 1)
+			}
+			// Archived habits get no pause, so they excuse no days
+			if info.archived {
+				habit.Status = models.HabitStatusArchived
+				habit.PausedAt = &habit.CreatedAt
+			}
+		}
+		plan.Habits = append(plan.Habits, PlannedHabit{Key: name, Habit: habit})
+	}
+
+	listed := make([]string, 0, len(habits))
+	for name := range habits {
+		listed = append(listed, name)
+	}
+	sort.Strings(listed)
+	for _, name := range listed {
+		if _, ok := columns[name]; !ok {
+			plan.unmapped("habit", name, "listed in Habits.csv but has no checkmarks column", 1)
+		}
+	}
+
+	return plan, nil
+}
+
+// parseLoopHabits reads Habits.csv. Older versions of Loop write NumRepetitions
+// and Interval, newer ones FrequencyNumerator, FrequencyDenominator, Type,
+// Unit, target and archive columns.
+func parseLoopHabits(content []byte) (map[string]loopHabit, error) {
+	records, err := readCSV(content)
+	if err != nil {
+		return nil, fmt.Errorf("Habits.csv: %w", err)
+	}
+	habits := map[string]loopHabit{}
+	if len(records) == 0 {
+		return habits, nil
+	}
+
+	index := map[string]int{}
+	for i, column := range records[0] {
+		index[strings.ToLower(strings.TrimSpace(column))] = i
+	}
+	field := func(record []string, names ...string) string {
+		for _, name := range names {
+			if i, ok := index[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+		}
+		return ""
+	}
+
+	for _, record := range records[1:] {
+		name := field(record, "name")
+		if name == "" {
+			continue
+		}
+		habit := loopHabit{
+			name:        name,
+			description: field(record, "description"),
+			numerical:   field(record, "type") == "1",
+			atMost:      field(record, "target type") == "1",
+			unit:        field(record, "unit"),
+			archived:    strings.EqualFold(field(record, "archived?"), "true"),
+		}
+		habit.target, _ = strconv.ParseFloat(field(record, "target value"), 64)
+		habit.numerator, _ = strconv.Atoi(field(record, "frequencynumerator", "numrepetitions"))
+		habit.denominator, _ = strconv.Atoi(field(record, "frequencydenominator", "interval"))
+		habits[name] = habit
+	}
+	return habits, nil
+}
+
+// parseLoopCheckmarks reads the combined Checkmarks.csv: a Date column followed
+// by one column per habit
+func parseLoopCheckmarks(content []byte) (map[string]map[string]int, error) {
+	records, err := readCSV(content)
+	if err != nil {
+		return nil, fmt.Errorf("Checkmarks.csv: %w", err)
+	}
+	if len(records) == 0 || len(records[0]) < 2 || !strings.EqualFold(strings.TrimSpace(records[0][0]), "date") {
+		return nil, fmt.Errorf("Checkmarks.csv must start with a Date column followed by one column per habit")
+	}
+
+	// Loop ends every line with a comma, which makes an unnamed last column
+	header := records[0]
+	columns := make(map[string]map[string]int, len(header)-1)
+	for _, name := range header[1:] {
+		if name = strings.TrimSpace(name); name != "" {
+			columns[name] = map[string]int{}
+		}
+	}
+	for line, record := range records[1:] {
+		date := strings.TrimSpace(record[0])
+		if _, err := time.Parse(dateLayout, date); err != nil {
+			return nil, fmt.Errorf("Checkmarks.csv line %d: invalid date %q", line+2, date)
+		}
+		for i := 1; i < len(record) && i < len(header); i++ {
+			if strings.TrimSpace(header[i]) == "" {
+				continue
+			}
+			value, err := strconv.Atoi(strings.TrimSpace(record[i]))
+			if err != nil {
+				return nil, fmt.Errorf("Checkmarks.csv line %d: invalid value %q", line+2, record[i])
+			}
+			columns[strings.TrimSpace(header[i])][date] = value
+		}
+	}
+	return columns, nil
+}
+
+// parseLoopHabitCheckmarks reads the Checkmarks.csv of a single habit folder,
+// made of date and value rows without a header
+func parseLoopHabitCheckmarks(content []byte) (map[string]int, error) {
+	records, err := readCSV(content)
+	if err != nil {
+		return nil, err
+	}
+	column := map[string]int{}
+	for line, record := range records {
+		if len(record) < 2 {
+			continue
+		}
+		date := strings.TrimSpace(record[0])
+		if _, err := time.Parse(dateLayout, date); err != nil {
+			if line == 0 {
+				continue // header
+			}
+			return nil, fmt.Errorf("line %d: invalid date %q", line+1, date)
+		}
+		value, err := strconv.Atoi(strings.TrimSpace(record[1]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid value %q", line+1, record[1])
+		}
+		column[date] = value
+	}
+	return column, nil
+}
+
+// readCSV reads every record of a CSV file, allowing rows of varying length
+func readCSV(content []byte) ([][]string, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	return reader.ReadAll()
+}
+
+// readZipFile reads a file of a zip archive, up to maxZipFileBytes and what is
+// left of budget, which it then deducts the file's size from
+func readZipFile(file *zip.File, budget *int64) ([]byte, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(io.LimitReader(reader, min(maxZipFileBytes, *budget)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > maxZipFileBytes {
+		return nil, fmt.Errorf("%s is too large", file.Name)
+	}
+	if int64(len(content)) > *budget {
+		return nil, fmt.Errorf("export is too large, files must not exceed %d MB in total", maxZipTotalBytes>>20)
+	}
+	*budget -= int64(len(content))
+	return content, nil
+}
@@ -0,0 +1,289 @@
+// Package insights looks for patterns in a user's task history: habits that
+// tend to be completed together or apart, and weekdays that stand out.
+package insights
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"habit-tracker/server/models"
+	"habit-tracker/server/streaks"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// dateLayout is the YYYY-MM-DD format used for task dates
+const dateLayout = "2006-01-02"
+
+// Insight kinds
+const (
+	KindCoCompletion = "co_completion"
+	KindWeekday      = "weekday"
+)
+
+// DefaultMinSamples is the default number of observations an insight needs:
+// days on which both habits were scheduled for a pair, tasks for a weekday
+const DefaultMinSamples = 10
+
+const (
+	// minCorrelation is the smallest absolute phi coefficient reported for a
+	// pair of habits
+	minCorrelation = 0.2
+	// minWeekdayGap is the smallest difference between a weekday's completion
+	// rate and the overall rate that is reported
+	minWeekdayGap = 0.1
+)
+
+// Insight is a pattern found in the task history. Rate is the completion rate
+// under the condition the insight describes and BaselineRate the rate it is
+// compared against. Score is the strength used for ranking, between 0 and 1.
+type Insight struct {
+	Kind         string  `json:"kind"`
+	Message      string  `json:"message"`
+	Score        float64 `json:"score"`
+	Samples      int     `json:"samples"`
+	HabitID      string  `json:"habit_id,omitempty"`
+	OtherHabitID string  `json:"other_habit_id,omitempty"`
+	Weekday      *int    `json:"weekday,omitempty"`
+	Rate         float64 `json:"rate"`
+	BaselineRate float64 `json:"baseline_rate"`
+}
+
+// Compute finds the insights in tasks, strongest first. habits names the
+// habits that take part in pairwise insights; tasks of other habits only count
+// towards weekday patterns. Frozen-day marker tasks are ignored.
+func Compute(tasks []models.Task, habits []models.Habit, minSamples int) []Insight {
+	if minSamples < 1 {
+		minSamples = DefaultMinSamples
+	}
+
+	kept := make([]models.Task, 0, len(tasks))
+	for _, task := range tasks {
+		if len(task.Date) >= len(dateLayout) && !streaks.IsFrozenTask(task) {
+			kept = append(kept, task)
+		}
+	}
+
+	insights := coCompletions(kept, habits, minSamples)
+	insights = append(insights, weekdays(kept, habits, minSamples)...)
+
+	sort.SliceStable(insights, func(i, j int) bool {
+		if insights[i].Score != insights[j].Score {
+			return insights[i].Score > insights[j].Score
+		}
+		return insights[i].Message < insights[j].Message
+	})
+	return insights
+}
+
+// coCompletions compares every pair of habits over the days both were
+// scheduled. The strength of the pair is the phi coefficient of their
+// completions. The message is phrased around the habit completed less often,
+// since that is the one the user is most likely trying to improve.
+func coCompletions(tasks []models.Task, habits []models.Habit, minSamples int) []Insight {
+	// done[day][habitID] is true when the habit's task was completed that day
+	done := make(map[string]map[primitive.ObjectID]bool)
+	for _, task := range tasks {
+		if task.HabitID == nil {
+			continue
+		}
+		day := task.Date[:len(dateLayout)]
+		if done[day] == nil {
+			done[day] = make(map[primitive.ObjectID]bool)
+		}
+		done[day][*task.HabitID] = done[day][*task.HabitID] || task.Completed
+	}
+
+	insights := []Insight{}
+	for i, first := range habits {
+		for _, second := range habits[i+1:] {
+			// counts[a][b] counts days by whether first (a) and second (b) were done
+			var counts [2][2]int
+			for _, habitsDone := range done {
+				a, okA := habitsDone[first.ID]
+				b, okB := habitsDone[second.ID]
+				if okA && okB {
+					counts[index(a)][index(b)]++
+				}
+			}
+
+			samples := counts[0][0] + counts[0][1] + counts[1][0] + counts[1][1]
+			if samples < minSamples {
+				continue
+			}
+			phi, ok := correlation(counts)
+			if !ok || math.Abs(phi) < minCorrelation {
+				continue
+			}
+
+			// target is the habit described, given is the habit conditioned on
+			target, given := first, second
+			targetRate := rate(counts[1][0]+counts[1][1], samples)
+			givenRate := rate(counts[0][1]+counts[1][1], samples)
+			withGiven := rate(counts[1][1], counts[0][1]+counts[1][1])
+			withoutGiven := rate(counts[1][0], counts[0][0]+counts[1][0])
+			if givenRate < targetRate {
+				target, given = second, first
+				withGiven = rate(counts[1][1], counts[1][0]+counts[1][1])
+				withoutGiven = rate(counts[0][1], counts[0][0]+counts[0][1])
+			}
+
+			insights = append(insights, Insight{
+				Kind:         KindCoCompletion,
+				Message:      pairMessage(target.Name, given.Name, withGiven, withoutGiven),
+				Score:        round(math.Abs(phi)),
+				Samples:      samples,
+				HabitID:      target.ID.Hex(),
+				OtherHabitID: given.ID.Hex(),
+				Rate:         withGiven,
+				BaselineRate: withoutGiven,
+			})
+		}
+	}
+	return insights
+}
+
+// correlation returns the phi coefficient of a 2x2 table, or false when one of
+// the habits always had the same outcome
+func correlation(counts [2][2]int) (float64, bool) {
+	a1 := float64(counts[1][0] + counts[1][1])
+	a0 := float64(counts[0][0] + counts[0][1])
+	b1 := float64(counts[0][1] + counts[1][1])
+	b0 := float64(counts[0][0] + counts[1][0])
+	denominator := math.Sqrt(a1 * a0 * b1 * b0)
+	if denominator == 0 {
+		return 0, false
+	}
+	numerator := float64(counts[1][1]*counts[0][0] - counts[1][0]*counts[0][1])
+	return numerator / denominator, true
+}
+
+// pairMessage describes how completing given changes how often target is
+// completed
+func pairMessage(target, given string, withGiven, withoutGiven float64) string {
+	switch {
+	case withoutGiven == 0:
+		return fmt.Sprintf("You only complete %s on days you complete %s", target, given)
+	case withGiven == 0:
+		return fmt.Sprintf("You never complete %s on days you complete %s", target, given)
+	case withGiven > withoutGiven:
+		return fmt.Sprintf("You complete %s %d%% more often on days you complete %s",
+			target, percent(withGiven/withoutGiven-1), given)
+	default:
+		return fmt.Sprintf("You complete %s %d%% less often on days you complete %s",
+			target, percent(1-withGiven/withoutGiven), given)
+	}
+}
+
+// weekdays reports the strongest and weakest weekday overall and the weakest
+// weekday of each habit, when they stand out from the matching overall rate
+func weekdays(tasks []models.Task, habits []models.Habit, minSamples int) []Insight {
+	insights := []Insight{}
+	if insight, ok := weekdayExtreme(tasks, minSamples, false); ok {
+		insight.Message = fmt.Sprintf("%ss are your strongest day", weekdayName(insight))
+		insights = append(insights, insight)
+	}
+	if insight, ok := weekdayExtreme(tasks, minSamples, true); ok {
+		insight.Message = fmt.Sprintf("%ss are your weakest day", weekdayName(insight))
+		insights = append(insights, insight)
+	}
+
+	byHabit := make(map[primitive.ObjectID][]models.Task)
+	for _, task := range tasks {
+		if task.HabitID != nil {
+			byHabit[*task.HabitID] = append(byHabit[*task.HabitID], task)
+		}
+	}
+	for _, habit := range habits {
+		insight, ok := weekdayExtreme(byHabit[habit.ID], minSamples, true)
+		if !ok {
+			continue
+		}
+		insight.HabitID = habit.ID.Hex()
+		insight.Message = fmt.Sprintf("You complete %s least often on %ss", habit.Name, weekdayName(insight))
+		insights = append(insights, insight)
+	}
+	return insights
+}
+
+// weekdayExtreme finds the weekday with the highest completion rate, or the
+// lowest when weakest is set, among weekdays with at least minSamples tasks.
+// It is only reported when it differs from the overall rate by minWeekdayGap.
+func weekdayExtreme(tasks []models.Task, minSamples int, weakest bool) (Insight, bool) {
+	var totals, completed [7]int
+	var total, done int
+	for _, task := range tasks {
+		date, err := time.Parse(dateLayout, task.Date[:len(dateLayout)])
+		if err != nil {
+			continue
+		}
+		weekday := date.Weekday()
+		totals[weekday]++
+		total++
+		if task.Completed {
+			completed[weekday]++
+			done++
+		}
+	}
+
+	overall := rate(done, total)
+	found := false
+	var best Insight
+	for weekday := range totals {
+		if totals[weekday] < minSamples {
+			continue
+		}
+		dayRate := rate(completed[weekday], totals[weekday])
+		if found && (weakest && dayRate >= best.Rate || !weakest && dayRate <= best.Rate) {
+			continue
+		}
+		weekday := weekday
+		found = true
+		best = Insight{
+			Kind:         KindWeekday,
+			Score:        round(math.Abs(dayRate - overall)),
+			Samples:      totals[weekday],
+			Weekday:      &weekday,
+			Rate:         dayRate,
+			BaselineRate: overall,
+		}
+	}
+
+	if !found || best.Score < minWeekdayGap {
+		return Insight{}, false
+	}
+	return best, true
+}
+
+// weekdayName returns the English name of an insight's weekday
+func weekdayName(insight Insight) string {
+	return time.Weekday(*insight.Weekday).String()
+}
+
+// index maps a completion flag to a table index
+func index(done bool) int {
+	if done {
+		return 1
+	}
+	return 0
+}
+
+// percent converts a ratio to a whole percentage
+func percent(ratio float64) int {
+	return int(math.Round(ratio * 100))
+}
+
+// rate returns part/total rounded to four decimal places, or 0 when total is 0
+func rate(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return round(float64(part) / float64(total))
+}
+
+// round rounds a rate to four decimal places
+func round(value float64) float64 {
+	return math.Round(value*10000) / 10000
+}
@@ -0,0 +1,55 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"habit-tracker/server/db"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// instanceID identifies this server process as a lease holder
+var instanceID = func() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}()
+
+// acquireLease claims key for ttl and reports whether this instance got it.
+// When several server instances run the same job, only the first to claim a
+// key does the work; the key stays taken, even for its holder, until the
+// lease expires.
+func acquireLease(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	filter := bson.M{"_id": key, "expires_at": bson.M{"$lte": now}}
+	update := bson.M{"$set": bson.M{
+		"owner":       instanceID,
+		"acquired_at": now,
+		"expires_at":  now.Add(ttl),
+	}}
+
+	// A live lease does not match the filter, so the upsert tries to insert a
+	// second document with the same _id and fails with a duplicate key error
+	_, err := db.LeaseColl.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// releaseLease gives up a lease this instance holds, so the work it guarded
+// can be retried before the lease would have expired
+func releaseLease(ctx context.Context, key string) error {
+	_, err := db.LeaseColl.DeleteOne(ctx, bson.M{"_id": key, "owner": instanceID})
+	return err
+}
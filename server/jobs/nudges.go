@@ -0,0 +1,133 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"habit-tracker/server/db"
+	"habit-tracker/server/models"
+	"habit-tracker/server/notify"
+	"habit-tracker/server/schedule"
+	"habit-tracker/server/streaks"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// nudgeLease keeps a user from being nudged twice on the same day
+const nudgeLease = 24 * time.Hour
+
+// StartStreakNudges checks for streaks at risk every interval
+func StartStreakNudges(notifier notify.Notifier, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := SendStreakNudges(notifier, time.Now()); err != nil {
+				log.Printf("Error sending streak nudges: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// SendStreakNudges notifies users whose nudge time has just passed in their
+// time zone and whose current streak breaks at their local end of day unless
+// today's remaining tasks are completed. Users in quiet hours are skipped.
+func SendStreakNudges(notifier notify.Notifier, now time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	cursor, err := db.UserColl.Find(ctx, bson.M{"settings.nudge_time": bson.M{"$nin": bson.A{nil, ""}}})
+	if err != nil {
+		return err
+	}
+	var users []models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		local := now.In(schedule.Location(user.Settings))
+		due, err := schedule.At(local, user.Settings.NudgeTime)
+		if err != nil || local.Before(due) || local.Sub(due) > reminderGrace {
+			continue
+		}
+		if schedule.InQuietHours(user.Settings, local) {
+			continue
+		}
+
+		if err := nudgeIfStreakAtRisk(ctx, notifier, user, local); err != nil {
+			log.Printf("Error nudging user %s: %v", user.ID.Hex(), err)
+		}
+	}
+
+	return nil
+}
+
+// nudgeIfStreakAtRisk sends the nudge when today still has open tasks and the
+// streak carried over from previous days is not zero
+func nudgeIfStreakAtRisk(ctx context.Context, notifier notify.Notifier, user models.User, local time.Time) error {
+	cursor, err := db.TaskColl.Find(ctx, bson.M{"user_id": user.ID, "deleted_at": nil})
+	if err != nil {
+		return err
+	}
+	var tasks []models.Task
+	if err := cursor.All(ctx, &tasks); err != nil {
+		return err
+	}
+
+	cursor, err = db.HabitColl.Find(ctx, bson.M{"user_id": user.ID, "deleted_at": nil})
+	if err != nil {
+		return err
+	}
+	var habits []models.Habit
+	if err := cursor.All(ctx, &habits); err != nil {
+		return err
+	}
+
+	inactive, excuses := streaks.Inactive(habits)
+	dateTasks := streaks.GroupByDate(streaks.ExcludeHabitTasks(tasks, inactive))
+
+	day := local.Format(schedule.DateLayout)
+	today := dateTasks[day]
+	if today.Total == 0 || today.Frozen || today.Completed == today.Total {
+		return nil
+	}
+
+	// With today incomplete, the current streak is the one it would end
+	streak := streaks.Current(dateTasks, excuses, local)
+	if streak == 0 {
+		return nil
+	}
+
+	key := fmt.Sprintf("streak-nudge:%s:%s", user.ID.Hex(), day)
+	acquired, err := acquireLease(ctx, key, nudgeLease)
+	if err != nil || !acquired {
+		return err
+	}
+
+	remaining := today.Total - today.Completed
+	err = notifier.Notify(ctx, models.Notification{
+		UserID: user.ID,
+		Kind:   models.NotificationStreakRisk,
+		Title:  fmt.Sprintf("Your %d-day streak is at risk", streak),
+		Body:   fmt.Sprintf("Complete your %d remaining task(s) before the end of the day to keep it going", remaining),
+		Data: map[string]string{
+			"date":      day,
+			"streak":    fmt.Sprint(streak),
+			"remaining": fmt.Sprint(remaining),
+		},
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		// Let the next tick try again instead of dropping the nudge
+		if releaseErr := releaseLease(ctx, key); releaseErr != nil {
+			log.Printf("Error releasing nudge lease %s: %v", key, releaseErr)
+		}
+		return err
+	}
+	return nil
+}
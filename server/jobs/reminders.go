@@ -0,0 +1,167 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"habit-tracker/server/db"
+	"habit-tracker/server/models"
+	"habit-tracker/server/notify"
+	"habit-tracker/server/schedule"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// reminderGrace is how late a reminder may still be sent, so a missed tick or
+// a restart does not drop it but a stale reminder is never sent hours later
+const reminderGrace = 15 * time.Minute
+
+// reminderLease keeps a sent reminder from being sent again by any instance
+const reminderLease = 24 * time.Hour
+
+// StartReminderDispatcher sends due habit reminders every interval
+func StartReminderDispatcher(notifier notify.Notifier, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := DispatchReminders(notifier, time.Now()); err != nil {
+				log.Printf("Error dispatching reminders: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// DispatchReminders sends the reminders due at now. A reminder is due when
+// its time has passed in the user's time zone within reminderGrace, the habit
+// is scheduled that day, its task is not completed yet and the user is not in
+// quiet hours. Reminders falling in quiet hours are skipped, not postponed.
+func DispatchReminders(notifier notify.Notifier, now time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	cursor, err := db.HabitColl.Find(ctx, bson.M{
+		"reminders.0": bson.M{"$exists": true},
+		"status":      models.HabitStatusActive,
+		"deleted_at":  nil,
+	})
+	if err != nil {
+		return err
+	}
+	var habits []models.Habit
+	if err := cursor.All(ctx, &habits); err != nil {
+		return err
+	}
+	if len(habits) == 0 {
+		return nil
+	}
+
+	users, err := fetchUsersByID(ctx, habits)
+	if err != nil {
+		return err
+	}
+
+	for _, habit := range habits {
+		user, ok := users[habit.UserID]
+		if !ok {
+			continue
+		}
+
+		local := now.In(schedule.Location(user.Settings))
+		if !schedule.IsScheduledOn(habit, local) || schedule.InQuietHours(user.Settings, local) {
+			continue
+		}
+
+		for _, reminder := range habit.Reminders {
+			due, err := schedule.At(local, reminder)
+			if err != nil || local.Before(due) || local.Sub(due) > reminderGrace {
+				continue
+			}
+
+			if err := sendReminder(ctx, notifier, habit, local, reminder); err != nil {
+				log.Printf("Error sending reminder for habit %s: %v", habit.ID.Hex(), err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// sendReminder notifies the user about a habit unless its task for the day is
+// already completed or another instance has sent the reminder
+func sendReminder(ctx context.Context, notifier notify.Notifier, habit models.Habit, local time.Time, reminder string) error {
+	day := local.Format(schedule.DateLayout)
+	nextDay := local.AddDate(0, 0, 1).Format(schedule.DateLayout)
+
+	completed, err := db.TaskColl.CountDocuments(ctx, bson.M{
+		"habit_id":   habit.ID,
+		"date":       bson.M{"$gte": day, "$lt": nextDay},
+		"completed":  true,
+		"deleted_at": nil,
+	})
+	if err != nil {
+		return err
+	}
+	if completed > 0 {
+		return nil
+	}
+
+	key := fmt.Sprintf("reminder:%s:%s:%s", habit.ID.Hex(), day, reminder)
+	acquired, err := acquireLease(ctx, key, reminderLease)
+	if err != nil || !acquired {
+		return err
+	}
+
+	body := fmt.Sprintf("Time for %s", habit.Name)
+	if habit.Target > 0 {
+		body = fmt.Sprintf("%s: %g %s", body, habit.Target, habit.Unit)
+	}
+	err = notifier.Notify(ctx, models.Notification{
+		UserID: habit.UserID,
+		Kind:   models.NotificationReminder,
+		Title:  habit.Name,
+		Body:   body,
+		Data: map[string]string{
+			"habit_id": habit.ID.Hex(),
+			"date":     day,
+			"time":     reminder,
+		},
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		// Let the next tick try again instead of dropping the reminder
+		if releaseErr := releaseLease(ctx, key); releaseErr != nil {
+			log.Printf("Error releasing reminder lease %s: %v", key, releaseErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// fetchUsersByID loads the owners of the given habits keyed by ID
+func fetchUsersByID(ctx context.Context, habits []models.Habit) (map[primitive.ObjectID]models.User, error) {
+	userIDs := make([]primitive.ObjectID, 0, len(habits))
+	for _, habit := range habits {
+		userIDs = append(userIDs, habit.UserID)
+	}
+
+	cursor, err := db.UserColl.Find(ctx, bson.M{"_id": bson.M{"$in": userIDs}})
+	if err != nil {
+		return nil, err
+	}
+	var users []models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	byID := make(map[primitive.ObjectID]models.User, len(users))
+	for _, user := range users {
+		byID[user.ID] = user
+	}
+	return byID, nil
+}
@@ -0,0 +1,87 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"habit-tracker/server/db"
+	"habit-tracker/server/models"
+	"habit-tracker/server/notify"
+	"habit-tracker/server/reports"
+	"habit-tracker/server/schedule"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// StartWeeklyReports stores last week's report for every user every interval
+func StartWeeklyReports(notifier notify.Notifier, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := StoreWeeklyReports(notifier, time.Now()); err != nil {
+				log.Printf("Error storing weekly reports: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// StoreWeeklyReports stores the report of each user's last completed week, in
+// their time zone, unless it is already stored, and tells the user it is ready
+func StoreWeeklyReports(notifier notify.Notifier, now time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	cursor, err := db.UserColl.Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	var users []models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		local := now.In(schedule.Location(user.Settings))
+		period, _ := reports.PeriodFor(models.ReportWeek, local.AddDate(0, 0, -7))
+		if err := storeReport(ctx, notifier, user, period, local); err != nil {
+			log.Printf("Error storing weekly report for user %s: %v", user.ID.Hex(), err)
+		}
+	}
+	return nil
+}
+
+// storeReport generates and stores a report unless one exists for the period
+func storeReport(ctx context.Context, notifier notify.Notifier, user models.User, period reports.Period, now time.Time) error {
+	key := bson.M{"user_id": user.ID, "period": period.Kind, "start_date": period.Start.Format(schedule.DateLayout)}
+	count, err := db.ReportColl.CountDocuments(ctx, key)
+	if err != nil || count > 0 {
+		return err
+	}
+
+	report, err := reports.Generate(user.ID, period, now)
+	if err != nil {
+		return err
+	}
+
+	// Another instance may store the same report meanwhile; the first one wins
+	result, err := db.ReportColl.UpdateOne(ctx, key, bson.M{"$setOnInsert": report}, options.Update().SetUpsert(true))
+	if err != nil || result.UpsertedID == nil {
+		return err
+	}
+
+	return notifier.Notify(ctx, models.Notification{
+		UserID: user.ID,
+		Kind:   models.NotificationReport,
+		Title:  "Your weekly review is ready",
+		Body: fmt.Sprintf("You completed %d of %d tasks from %s to %s",
+			report.Completion.Completed, report.Completion.Tasks, report.StartDate, report.EndDate),
+		Data:      map[string]string{"start_date": report.StartDate, "period": report.Period},
+		CreatedAt: time.Now(),
+	})
+}
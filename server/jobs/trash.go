@@ -0,0 +1,84 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"habit-tracker/server/db"
+	"habit-tracker/server/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// StartTrashPurge purges expired trash once at startup and then every interval
+func StartTrashPurge(retention, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := PurgeTrash(retention); err != nil {
+				log.Printf("Error purging trash: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// PurgeTrash permanently deletes tasks and habits that have been in the trash
+// for longer than retention. Habits stacked on a purged habit are re-linked to
+// its predecessor so chains stay intact.
+func PurgeTrash(retention time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	expired := bson.M{"deleted_at": bson.M{"$lt": time.Now().Add(-retention)}}
+
+	taskResult, err := db.TaskColl.DeleteMany(ctx, expired)
+	if err != nil {
+		return err
+	}
+
+	cursor, err := db.HabitColl.Find(ctx, expired)
+	if err != nil {
+		return err
+	}
+	var habits []models.Habit
+	if err := cursor.All(ctx, &habits); err != nil {
+		return err
+	}
+
+	purged := 0
+	for _, expiredHabit := range habits {
+		// Re-read each habit as it is removed: purging an earlier habit of the
+		// same chain may have re-linked it
+		var habit models.Habit
+		err := db.HabitColl.FindOneAndDelete(ctx, bson.M{"_id": expiredHabit.ID}).Decode(&habit)
+		if err == mongo.ErrNoDocuments {
+			// Another instance purged it first
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		relink := bson.M{"$unset": bson.M{"predecessor_id": ""}}
+		if habit.PredecessorID != nil {
+			relink = bson.M{"$set": bson.M{"predecessor_id": *habit.PredecessorID}}
+		}
+		if _, err := db.HabitColl.UpdateMany(ctx, bson.M{"predecessor_id": habit.ID}, relink); err != nil {
+			return err
+		}
+		if _, err := db.RelapseColl.DeleteMany(ctx, bson.M{"habit_id": habit.ID}); err != nil {
+			return err
+		}
+		purged++
+	}
+
+	if taskResult.DeletedCount > 0 || purged > 0 {
+		log.Printf("Purged %d tasks and %d habits from trash", taskResult.DeletedCount, purged)
+	}
+	return nil
+}
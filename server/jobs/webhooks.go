@@ -0,0 +1,135 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"habit-tracker/server/db"
+	"habit-tracker/server/models"
+	"habit-tracker/server/schedule"
+	"habit-tracker/server/streaks"
+	"habit-tracker/server/webhooks"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// deliveryBatch caps how many webhook deliveries one tick attempts
+const deliveryBatch = 100
+
+// streakBrokenLease keeps a broken streak from being published twice
+const streakBrokenLease = 48 * time.Hour
+
+// StartWebhookDeliveries works the webhook delivery queue every interval
+func StartWebhookDeliveries(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := webhooks.DeliverDue(time.Now(), deliveryBatch); err != nil {
+				log.Printf("Error delivering webhooks: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// StartStreakBrokenEvents publishes streak.broken events every interval
+func StartStreakBrokenEvents(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := PublishBrokenStreaks(time.Now()); err != nil {
+				log.Printf("Error publishing broken streaks: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// PublishBrokenStreaks publishes a streak.broken event for users subscribed to
+// it whose streak ended yesterday in their time zone: the days before it form a
+// streak and yesterday was neither perfect, frozen nor an empty paused day
+func PublishBrokenStreaks(now time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	userIDs, err := db.WebhookColl.Distinct(ctx, "user_id", bson.M{"events": models.EventStreakBroken, "active": true})
+	if err != nil {
+		return err
+	}
+
+	for _, value := range userIDs {
+		userID, ok := value.(primitive.ObjectID)
+		if !ok {
+			continue
+		}
+		if err := publishIfStreakBroken(ctx, userID, now); err != nil {
+			log.Printf("Error checking streak of user %s: %v", userID.Hex(), err)
+		}
+	}
+	return nil
+}
+
+// publishIfStreakBroken checks a single user for a streak broken yesterday
+func publishIfStreakBroken(ctx context.Context, userID primitive.ObjectID, now time.Time) error {
+	var user models.User
+	if err := db.UserColl.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		return err
+	}
+
+	cursor, err := db.TaskColl.Find(ctx, bson.M{"user_id": userID, "deleted_at": nil})
+	if err != nil {
+		return err
+	}
+	var tasks []models.Task
+	if err := cursor.All(ctx, &tasks); err != nil {
+		return err
+	}
+
+	cursor, err = db.HabitColl.Find(ctx, bson.M{"user_id": userID, "deleted_at": nil})
+	if err != nil {
+		return err
+	}
+	var habits []models.Habit
+	if err := cursor.All(ctx, &habits); err != nil {
+		return err
+	}
+
+	inactive, excuses := streaks.Inactive(habits)
+	dateTasks := streaks.GroupByDate(streaks.ExcludeHabitTasks(tasks, inactive))
+
+	yesterday := now.In(schedule.Location(user.Settings)).AddDate(0, 0, -1)
+	day := yesterday.Format(schedule.DateLayout)
+	info := dateTasks[day]
+	if info.Perfect() || info.Frozen {
+		return nil
+	}
+	if info.Total == 0 && excuses.Covers(yesterday) {
+		return nil
+	}
+
+	length := streaks.EndingOn(dateTasks, excuses, yesterday.AddDate(0, 0, -1))
+	if length == 0 {
+		return nil
+	}
+
+	acquired, err := acquireLease(ctx, fmt.Sprintf("streak-broken:%s:%s", userID.Hex(), day), streakBrokenLease)
+	if err != nil || !acquired {
+		return err
+	}
+
+	return webhooks.Publish(userID, models.EventStreakBroken, map[string]interface{}{
+		"user_id":      userID.Hex(),
+		"date":         day,
+		"streak":       length,
+		"tasks":        info.Total,
+		"completed":    info.Completed,
+		"longest_ever": streaks.Longest(dateTasks),
+	})
+}
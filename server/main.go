@@ -3,9 +3,13 @@ package main
 import (
 	"log"
 	"os"
+	"time"
 
+	"habit-tracker/server/config"
 	"habit-tracker/server/db"
 	"habit-tracker/server/handlers"
+	"habit-tracker/server/jobs"
+	"habit-tracker/server/notify"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -47,17 +51,133 @@ func main() {
 		api.GET("/users/:id", handlers.GetUserById)
 		api.POST("/users", handlers.CreateUser)
 		api.PATCH("/users/:id", handlers.UpdateUser)
+		api.GET("/settings/user/:userId", handlers.GetUserSettings)
+		api.PATCH("/settings/user/:userId", handlers.UpdateUserSettings)
 
 		// Task routes
 		api.GET("/tasks", handlers.GetTasks)
 		api.GET("/tasks/user/:userId", handlers.GetTasksByUserId)
 		api.GET("/tasks/streak/:userId", handlers.GetUserStreak)
+		api.GET("/tasks/chains", handlers.GetDayChains)
+		api.GET("/tasks/trash", handlers.GetTaskTrash)
+		api.POST("/tasks/:id/restore", handlers.RestoreTask)
+		api.GET("/tasks/:id/next", handlers.GetNextHabits)
 		api.POST("/tasks", handlers.CreateTask)
+		api.POST("/tasks/parse", handlers.ParseQuickAdd)
+		api.PATCH("/tasks/order", handlers.ReorderTasks)
 		api.PATCH("/tasks/:id", handlers.UpdateTask)
 		api.DELETE("/tasks/:id", handlers.DeleteTask)
 		api.DELETE("/tasks/frozen", handlers.DeleteFrozenTasks)
+
+		// Task checklist routes
+		api.POST("/tasks/:id/items", handlers.AddChecklistItem)
+		api.PATCH("/tasks/:id/items/order", handlers.ReorderChecklistItems)
+		api.PATCH("/tasks/:id/items/:itemId", handlers.UpdateChecklistItem)
+		api.DELETE("/tasks/:id/items/:itemId", handlers.DeleteChecklistItem)
+
+		// Habit routes
+		api.GET("/habits", handlers.GetHabits)
+		api.GET("/habits/:id", handlers.GetHabitById)
+		api.POST("/habits", handlers.CreateHabit)
+		api.PATCH("/habits/:id", handlers.UpdateHabit)
+		api.DELETE("/habits/:id", handlers.DeleteHabit)
+		api.GET("/habits/:id/relapses", handlers.GetRelapses)
+		api.POST("/habits/:id/relapses", handlers.LogRelapse)
+		api.DELETE("/habits/:id/relapses/:relapseId", handlers.DeleteRelapse)
+		api.GET("/habits/:id/abstinence", handlers.GetAbstinence)
+		api.GET("/habits/chains/stats", handlers.GetChainStats)
+		api.GET("/habits/trash", handlers.GetHabitTrash)
+		api.POST("/habits/:id/restore", handlers.RestoreHabit)
+		api.POST("/habits/generate", handlers.GenerateHabitTasks)
+
+		// Habit template routes
+		api.GET("/templates/packs", handlers.GetTemplatePacks)
+		api.GET("/templates/packs/:id", handlers.GetTemplatePack)
+		api.POST("/templates/packs/:id/instantiate", handlers.InstantiateTemplatePack)
+
+		// Stats routes
+		api.GET("/stats", handlers.GetStats)
+		api.GET("/stats/heatmap", handlers.GetHeatmap)
+
+		// Export and import routes
+		api.GET("/export/tasks.csv", handlers.ExportTasksCSV)
+		api.POST("/import/tasks", handlers.ImportTasksCSV)
+		api.POST("/import/apps/:source", handlers.ImportFromApp)
+		api.GET("/account/user/:userId/export", handlers.ExportAccount)
+		api.POST("/account/user/:userId/import", handlers.ImportAccount)
+
+		// Calendar routes
+		api.POST("/calendar/user/:userId", handlers.CreateCalendarFeed)
+		api.DELETE("/calendar/user/:userId", handlers.DeleteCalendarFeed)
+		api.GET("/calendar/feed/:token", handlers.GetCalendarFeed)
+
+		// Insight routes
+		api.GET("/insights", handlers.GetInsights)
+
+		// Forecast routes
+		api.GET("/forecast", handlers.GetForecast)
+
+		// Report routes
+		api.GET("/reports/user/:userId", handlers.GetReport)
+		api.GET("/reports/user/:userId/saved", handlers.GetSavedReports)
+		api.GET("/reports/:id", handlers.GetSavedReport)
+
+		// Achievement routes
+		api.GET("/achievements/user/:userId", handlers.GetUserAchievements)
+
+		// Notification routes
+		api.GET("/notifications/user/:userId", handlers.GetNotifications)
+		api.GET("/notifications/user/:userId/unread", handlers.GetUnreadNotificationCount)
+		api.PATCH("/notifications/user/:userId/read", handlers.MarkAllNotificationsRead)
+		api.PATCH("/notifications/:id/read", handlers.MarkNotificationRead)
+
+		// Webhook routes
+		api.GET("/webhooks", handlers.GetWebhooks)
+		api.POST("/webhooks", handlers.CreateWebhook)
+		api.PATCH("/webhooks/:id", handlers.UpdateWebhook)
+		api.DELETE("/webhooks/:id", handlers.DeleteWebhook)
+		api.GET("/webhooks/:id/deliveries", handlers.GetWebhookDeliveries)
+		api.POST("/webhooks/:id/test", handlers.TestWebhook)
+		api.POST("/webhooks/:id/secret", handlers.RotateWebhookSecret)
+
+		// Inbound hook routes
+		api.GET("/inbound-hooks", handlers.GetInboundHooks)
+		api.POST("/inbound-hooks", handlers.CreateInboundHook)
+		api.PATCH("/inbound-hooks/:id", handlers.UpdateInboundHook)
+		api.DELETE("/inbound-hooks/:id", handlers.DeleteInboundHook)
+		api.POST("/inbound-hooks/:id/token", handlers.RotateInboundHookToken)
+		api.GET("/inbound-hooks/:id/events", handlers.GetInboundEvents)
+		api.POST("/inbound/:token", handlers.ReceiveInboundEvent)
+
+		// Points routes
+		api.GET("/points/user/:userId", handlers.GetUserPoints)
+		api.GET("/points/user/:userId/ledger", handlers.GetPointsLedger)
+		api.POST("/points/user/:userId/recompute", handlers.RecomputeUserPoints)
+
+		// Reward routes
+		api.GET("/rewards", handlers.GetRewards)
+		api.GET("/rewards/redemptions", handlers.GetRedemptions)
+		api.POST("/rewards", handlers.CreateReward)
+		api.PATCH("/rewards/:id", handlers.UpdateReward)
+		api.DELETE("/rewards/:id", handlers.DeleteReward)
+		api.POST("/rewards/:id/redeem", handlers.RedeemReward)
+
+		// Goal routes
+		api.GET("/goals", handlers.GetGoals)
+		api.GET("/goals/:id", handlers.GetGoalById)
+		api.POST("/goals", handlers.CreateGoal)
+		api.PATCH("/goals/:id", handlers.UpdateGoal)
+		api.DELETE("/goals/:id", handlers.DeleteGoal)
 	}
 
+	// Background jobs
+	jobs.StartTrashPurge(config.TrashRetention(), time.Hour)
+	jobs.StartReminderDispatcher(notify.Default, time.Minute)
+	jobs.StartStreakNudges(notify.Default, time.Minute)
+	jobs.StartWebhookDeliveries(10 * time.Second)
+	jobs.StartStreakBrokenEvents(15 * time.Minute)
+	jobs.StartWeeklyReports(notify.Default, time.Hour)
+
 	// Start server
 	port := os.Getenv("PORT")
 	if port == "" {
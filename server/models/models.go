@@ -7,20 +7,362 @@ import (
 )
 
 type User struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name          string             `bson:"name" json:"name" validate:"required"`
+	Email         string             `bson:"email" json:"email"`
+	PasswordHash  string             `bson:"password_hash" json:"password_hash" validate:"required"`
+	Streak        int                `bson:"streak" json:"streak"`
+	AvatarURL     string             `bson:"avatar_url" json:"avatarURL"`
+	XP            int                `bson:"xp" json:"xp"`
+	PointsSpent   int                `bson:"points_spent" json:"points_spent"`
+	Settings      UserSettings       `bson:"settings" json:"settings"`
+	CalendarToken string             `bson:"calendar_token,omitempty" json:"-"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// UserSettings holds a user's preferences for reminders and nudges. Times are
+// HH:MM in the user's time zone, an IANA name such as "Europe/Paris".
+type UserSettings struct {
+	Timezone        string `bson:"timezone,omitempty" json:"timezone,omitempty"`
+	QuietHoursStart string `bson:"quiet_hours_start,omitempty" json:"quiet_hours_start,omitempty"`
+	QuietHoursEnd   string `bson:"quiet_hours_end,omitempty" json:"quiet_hours_end,omitempty"`
+	NudgeTime       string `bson:"nudge_time,omitempty" json:"nudge_time,omitempty"`
+}
+
+type Task struct {
+	ID        primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID  `bson:"user_id" json:"user_id"`
+	HabitID   *primitive.ObjectID `bson:"habit_id,omitempty" json:"habit_id,omitempty"`
+	Name      string              `bson:"name" json:"name"`
+	Completed bool                `bson:"completed" json:"completed"`
+	Date      string              `bson:"date" json:"date"`
+	Position  int                 `bson:"position" json:"position"`
+	Amount    *float64            `bson:"amount,omitempty" json:"amount,omitempty"`
+	Items     []ChecklistItem     `bson:"items,omitempty" json:"items,omitempty"`
+	Note      string              `bson:"note,omitempty" json:"note,omitempty"`
+	CreatedAt time.Time           `bson:"created_at" json:"created_at"`
+	UpdatedAt *time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+	Revision  int                 `bson:"revision,omitempty" json:"revision,omitempty"`
+	DeletedAt *time.Time          `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+}
+
+// ChecklistItem is an ordered subtask of a task
+type ChecklistItem struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	Name      string             `bson:"name" json:"name"`
+	Completed bool               `bson:"completed" json:"completed"`
+}
+
+// Habit types
+const (
+	HabitTypeBuild = "build"
+	HabitTypeAvoid = "avoid"
+)
+
+// Habit difficulties, which weigh the points a completion earns
+const (
+	DifficultyEasy   = "easy"
+	DifficultyMedium = "medium"
+	DifficultyHard   = "hard"
+)
+
+// Habit statuses. Paused and archived habits stop generating tasks.
+const (
+	HabitStatusActive   = "active"
+	HabitStatusPaused   = "paused"
+	HabitStatusArchived = "archived"
+)
+
+type Habit struct {
+	ID            primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	UserID        primitive.ObjectID  `bson:"user_id" json:"user_id"`
+	Name          string              `bson:"name" json:"name"`
+	Type          string              `bson:"type" json:"type"`
+	StartDate     string              `bson:"start_date" json:"start_date"`
+	PredecessorID *primitive.ObjectID `bson:"predecessor_id,omitempty" json:"predecessor_id,omitempty"`
+	Weekdays      []int               `bson:"weekdays,omitempty" json:"weekdays,omitempty"`
+	Difficulty    string              `bson:"difficulty" json:"difficulty"`
+	Target        float64             `bson:"target,omitempty" json:"target,omitempty"`
+	Unit          string              `bson:"unit,omitempty" json:"unit,omitempty"`
+	Category      string              `bson:"category,omitempty" json:"category,omitempty"`
+	Reminders     []string            `bson:"reminders,omitempty" json:"reminders,omitempty"`
+	Status        string              `bson:"status" json:"status"`
+	PausedAt      *time.Time          `bson:"paused_at,omitempty" json:"paused_at,omitempty"`
+	Pauses        []HabitPause        `bson:"pauses,omitempty" json:"pauses,omitempty"`
+	CreatedAt     time.Time           `bson:"created_at" json:"created_at"`
+	UpdatedAt     *time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+	Revision      int                 `bson:"revision,omitempty" json:"revision,omitempty"`
+	DeletedAt     *time.Time          `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+}
+
+// HabitPause is a span during which a habit was paused. It ends when the habit
+// is resumed or archived; End is nil while the habit is still paused.
+type HabitPause struct {
+	Start time.Time  `bson:"start" json:"start"`
+	End   *time.Time `bson:"end,omitempty" json:"end,omitempty"`
+}
+
+type Relapse struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	HabitID    primitive.ObjectID `bson:"habit_id" json:"habit_id"`
+	UserID     primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Date       string             `bson:"date" json:"date"`
+	Note       string             `bson:"note" json:"note"`
+	OccurredAt time.Time          `bson:"occurred_at" json:"occurred_at"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// Goal metrics
+const (
+	GoalMetricCount  = "count"
+	GoalMetricAmount = "amount"
+)
+
+// Goal is a target spanning many days, measured over the completed tasks of
+// one or more habits. Count goals add up completions, amount goals add up
+// the amount logged on each completed task (e.g. km run).
+type Goal struct {
+	ID         primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	UserID     primitive.ObjectID   `bson:"user_id" json:"user_id"`
+	Name       string               `bson:"name" json:"name"`
+	HabitIDs   []primitive.ObjectID `bson:"habit_ids" json:"habit_ids"`
+	Metric     string               `bson:"metric" json:"metric"`
+	Target     float64              `bson:"target" json:"target"`
+	Unit       string               `bson:"unit" json:"unit"`
+	StartDate  string               `bson:"start_date" json:"start_date"`
+	EndDate    string               `bson:"end_date" json:"end_date"`
+	Milestones []float64            `bson:"milestones" json:"milestones"`
+	CreatedAt  time.Time            `bson:"created_at" json:"created_at"`
+}
+
+// Achievement records when a user unlocked an achievement
+type Achievement struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID     primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Key        string             `bson:"key" json:"key"`
+	UnlockedAt time.Time          `bson:"unlocked_at" json:"unlocked_at"`
+}
+
+// Points ledger reasons
+const (
+	PointsReasonCompleted   = "task_completed"
+	PointsReasonUncompleted = "task_uncompleted"
+	PointsReasonRecomputed  = "rules_recomputed"
+)
+
+// PointsEntry is one line of a user's XP ledger. A user's XP is the sum of
+// their entries; entries are never changed once written. Seq numbers the
+// entries of a task from 1.
+type PointsEntry struct {
 	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Name         string             `bson:"name" json:"name" validate:"required"`
-	Email        string             `bson:"email" json:"email"`
-	PasswordHash string             `bson:"password_hash" json:"password_hash" validate:"required"`
-	Streak       int                `bson:"streak" json:"streak"`
-	AvatarURL    string             `bson:"avatar_url" json:"avatarURL"`
+	UserID       primitive.ObjectID `bson:"user_id" json:"user_id"`
+	TaskID       primitive.ObjectID `bson:"task_id" json:"task_id"`
+	Seq          int                `bson:"seq,omitempty" json:"seq,omitempty"`
+	Points       int                `bson:"points" json:"points"`
+	Reason       string             `bson:"reason" json:"reason"`
+	Difficulty   string             `bson:"difficulty" json:"difficulty"`
+	Multiplier   float64            `bson:"multiplier" json:"multiplier"`
+	RulesVersion int                `bson:"rules_version" json:"rules_version"`
 	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
 }
 
-type Task struct {
+// Reward is something a user promises themselves for a number of points
+type Reward struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID      primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Name        string             `bson:"name" json:"name"`
+	Description string             `bson:"description" json:"description"`
+	Cost        int                `bson:"cost" json:"cost"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// Redemption records a reward being bought. The reward's name and cost are
+// copied so the history survives later edits.
+type Redemption struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID     primitive.ObjectID `bson:"user_id" json:"user_id"`
+	RewardID   primitive.ObjectID `bson:"reward_id" json:"reward_id"`
+	RewardName string             `bson:"reward_name" json:"reward_name"`
+	Cost       int                `bson:"cost" json:"cost"`
+	RedeemedAt time.Time          `bson:"redeemed_at" json:"redeemed_at"`
+}
+
+// Notification kinds
+const (
+	NotificationReminder    = "reminder"
+	NotificationAchievement = "achievement"
+	NotificationStreakRisk  = "streak_at_risk"
+	NotificationReport      = "report"
+)
+
+// Notification is a message sent to a user, such as a habit reminder
+type Notification struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Kind      string             `bson:"kind" json:"kind"`
+	Title     string             `bson:"title" json:"title"`
+	Body      string             `bson:"body" json:"body"`
+	Data      map[string]string  `bson:"data,omitempty" json:"data,omitempty"`
+	ReadAt    *time.Time         `bson:"read_at" json:"read_at"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// Webhook event types
+const (
+	EventTaskCreated         = "task.created"
+	EventTaskCompleted       = "task.completed"
+	EventStreakBroken        = "streak.broken"
+	EventAchievementUnlocked = "achievement.unlocked"
+	EventPing                = "ping"
+)
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook is a user-configured endpoint that receives the events it subscribes to.
+// Payloads are signed with Secret.
+type Webhook struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	URL       string             `bson:"url" json:"url"`
+	Events    []string           `bson:"events" json:"events"`
+	Secret    string             `bson:"secret" json:"secret,omitempty"`
+	Active    bool               `bson:"active" json:"active"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// WebhookDelivery is one event queued for a webhook, with a log of every
+// attempt to send it
+type WebhookDelivery struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	WebhookID     primitive.ObjectID `bson:"webhook_id" json:"webhook_id"`
+	UserID        primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Event         string             `bson:"event" json:"event"`
+	Payload       string             `bson:"payload" json:"payload"`
+	Status        string             `bson:"status" json:"status"`
+	Attempts      []WebhookAttempt   `bson:"attempts" json:"attempts"`
+	NextAttemptAt time.Time          `bson:"next_attempt_at" json:"next_attempt_at"`
+	DeliveredAt   *time.Time         `bson:"delivered_at,omitempty" json:"delivered_at,omitempty"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// WebhookAttempt is the outcome of one HTTP request to a webhook
+type WebhookAttempt struct {
+	At         time.Time `bson:"at" json:"at"`
+	StatusCode int       `bson:"status_code,omitempty" json:"status_code,omitempty"`
+	Error      string    `bson:"error,omitempty" json:"error,omitempty"`
+	DurationMS int64     `bson:"duration_ms" json:"duration_ms"`
+}
+
+// Inbound event statuses
+const (
+	InboundProcessing = "processing"
+	InboundProcessed  = "processed"
+	InboundIgnored    = "ignored"
+	InboundError      = "error"
+)
+
+// InboundHook is a per-user URL other systems post events to. Token is the
+// secret part of the URL; Rules decide which habits an event completes.
+type InboundHook struct {
 	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
 	Name      string             `bson:"name" json:"name"`
-	Completed bool               `bson:"completed" json:"completed"`
-	Date      string             `bson:"date" json:"date"`
+	Token     string             `bson:"token" json:"token"`
+	Rules     []InboundRule      `bson:"rules" json:"rules"`
+	Active    bool               `bson:"active" json:"active"`
 	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
 }
+
+// InboundRule completes a habit when the value at Path in an event payload
+// equals Equals, or merely exists when Equals is empty. The day comes from
+// DatePath when set, otherwise it is the day the event is received.
+type InboundRule struct {
+	Path     string             `bson:"path" json:"path"`
+	Equals   string             `bson:"equals,omitempty" json:"equals,omitempty"`
+	HabitID  primitive.ObjectID `bson:"habit_id" json:"habit_id"`
+	DatePath string             `bson:"date_path,omitempty" json:"date_path,omitempty"`
+}
+
+// InboundEvent logs an event received on an inbound hook and what it did.
+// DeliveryKey identifies replays of the same event.
+type InboundEvent struct {
+	ID          primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	HookID      primitive.ObjectID  `bson:"hook_id" json:"hook_id"`
+	UserID      primitive.ObjectID  `bson:"user_id" json:"user_id"`
+	DeliveryKey string              `bson:"delivery_key" json:"delivery_key"`
+	Payload     string              `bson:"payload" json:"payload"`
+	Status      string              `bson:"status" json:"status"`
+	Completions []InboundCompletion `bson:"completions" json:"completions"`
+	Error       string              `bson:"error,omitempty" json:"error,omitempty"`
+	Replays     int                 `bson:"replays" json:"replays"`
+	ReceivedAt  time.Time           `bson:"received_at" json:"received_at"`
+}
+
+// InboundCompletion is a habit task completed by an inbound event
+type InboundCompletion struct {
+	HabitID primitive.ObjectID `bson:"habit_id" json:"habit_id"`
+	Date    string             `bson:"date" json:"date"`
+	TaskID  primitive.ObjectID `bson:"task_id" json:"task_id"`
+	Created bool               `bson:"created" json:"created"`
+}
+
+// Report periods
+const (
+	ReportWeek  = "week"
+	ReportMonth = "month"
+)
+
+// Report summarizes a user's week or month for review
+type Report struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID          primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Period          string             `bson:"period" json:"period"`
+	StartDate       string             `bson:"start_date" json:"start_date"`
+	EndDate         string             `bson:"end_date" json:"end_date"`
+	Completion      ReportCompletion   `bson:"completion" json:"completion"`
+	Previous        ReportCompletion   `bson:"previous" json:"previous"`
+	RateChange      float64            `bson:"rate_change" json:"rate_change"`
+	Streak          ReportStreak       `bson:"streak" json:"streak"`
+	MostConsistent  []ReportHabit      `bson:"most_consistent" json:"most_consistent"`
+	LeastConsistent []ReportHabit      `bson:"least_consistent" json:"least_consistent"`
+	Notes           []ReportNote       `bson:"notes" json:"notes"`
+	GeneratedAt     time.Time          `bson:"generated_at" json:"generated_at"`
+}
+
+// ReportCompletion counts a period's tasks
+type ReportCompletion struct {
+	Tasks       int     `bson:"tasks" json:"tasks"`
+	Completed   int     `bson:"completed" json:"completed"`
+	Rate        float64 `bson:"rate" json:"rate"`
+	PerfectDays int     `bson:"perfect_days" json:"perfect_days"`
+}
+
+// ReportStreak is the streak going into and coming out of a period
+type ReportStreak struct {
+	Start   int `bson:"start" json:"start"`
+	End     int `bson:"end" json:"end"`
+	Change  int `bson:"change" json:"change"`
+	Longest int `bson:"longest" json:"longest"`
+}
+
+// ReportHabit is how consistently a habit's tasks were completed in a period
+type ReportHabit struct {
+	HabitID   primitive.ObjectID `bson:"habit_id" json:"habit_id"`
+	Name      string             `bson:"name" json:"name"`
+	Tasks     int                `bson:"tasks" json:"tasks"`
+	Completed int                `bson:"completed" json:"completed"`
+	Rate      float64            `bson:"rate" json:"rate"`
+}
+
+// ReportNote is a note written on a task or relapse during a period
+type ReportNote struct {
+	Date   string `bson:"date" json:"date"`
+	Source string `bson:"source" json:"source"`
+	Name   string `bson:"name" json:"name"`
+	Text   string `bson:"text" json:"text"`
+}
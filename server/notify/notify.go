@@ -0,0 +1,44 @@
+// Package notify delivers notifications to users. Senders depend on the
+// Notifier interface so delivery channels can be swapped or combined.
+package notify
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"habit-tracker/server/db"
+	"habit-tracker/server/models"
+)
+
+// Notifier delivers a notification to its user
+type Notifier interface {
+	Notify(ctx context.Context, notification models.Notification) error
+}
+
+// Default is the notifier used by handlers and background jobs
+var Default Notifier = InboxNotifier{}
+
+// InboxNotifier stores notifications in the user's in-app inbox
+type InboxNotifier struct{}
+
+// Notify inserts the notification as unread
+func (InboxNotifier) Notify(ctx context.Context, notification models.Notification) error {
+	notification.ReadAt = nil
+	if notification.CreatedAt.IsZero() {
+		notification.CreatedAt = time.Now()
+	}
+	_, err := db.NotificationColl.InsertOne(ctx, notification)
+	return err
+}
+
+// LogNotifier writes notifications to the server log. It is useful in
+// development and as a fallback when no other channel is configured.
+type LogNotifier struct{}
+
+// Notify logs the notification
+func (LogNotifier) Notify(ctx context.Context, notification models.Notification) error {
+	log.Printf("Notification for user %s [%s]: %s - %s",
+		notification.UserID.Hex(), notification.Kind, notification.Title, notification.Body)
+	return nil
+}
@@ -0,0 +1,309 @@
+// Package parser turns quick-add text such as "run 5k every Mon Wed Fri at 7am"
+// or "read 20 pages daily starting tomorrow" into a structured task or habit.
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Kinds of interpretation
+const (
+	KindTask  = "task"
+	KindHabit = "habit"
+)
+
+// Habit types, mirroring models.HabitTypeBuild and models.HabitTypeAvoid
+const (
+	TypeBuild = "build"
+	TypeAvoid = "avoid"
+)
+
+// dateLayout is the YYYY-MM-DD format used for task and habit dates
+const dateLayout = "2006-01-02"
+
+// ErrEmptyName is returned when nothing is left to name the task after parsing
+var ErrEmptyName = errors.New("could not find what to do in the text")
+
+// Result is the structured interpretation of quick-add text. A habit is
+// recognized by a recurrence or by abstaining; anything else is a one-off task
+// on Date. Type is only set for habits.
+type Result struct {
+	Kind      string  `json:"kind"`
+	Name      string  `json:"name"`
+	Type      string  `json:"type,omitempty"`
+	Date      string  `json:"date,omitempty"`
+	StartDate string  `json:"start_date,omitempty"`
+	Weekdays  []int   `json:"weekdays,omitempty"`
+	Time      string  `json:"time,omitempty"`
+	Target    float64 `json:"target,omitempty"`
+	Unit      string  `json:"unit,omitempty"`
+}
+
+// timePrepositions may introduce a time of day; they go with the time rather
+// than the name ("sleep by 10pm" is named "Sleep")
+const timePrepositions = `(?:at|by|before|around)`
+
+const weekdayPattern = `(?:mon(?:day)?|tue(?:s(?:day)?)?|wed(?:nesday)?|thu(?:r(?:s(?:day)?)?)?|fri(?:day)?|sat(?:urday)?|sun(?:day)?)`
+
+var (
+	timeRe       = regexp.MustCompile(`(?i)\b(?:` + timePrepositions + `\s+)?(\d{1,2})(?::(\d{2}))?\s*(am|pm)\b|\b` + timePrepositions + `\s+(\d{1,2}):(\d{2})\b|\b` + timePrepositions + `\s+(noon|midnight)\b`)
+	startRe      = regexp.MustCompile(`(?i)\b(?:starting|from|beginning)\s+(today|tomorrow|next\s+` + weekdayPattern + `|` + weekdayPattern + `|\d{4}-\d{2}-\d{2})\b`)
+	dailyRe      = regexp.MustCompile(`(?i)\b(?:every\s*day|daily|each\s+day)\b`)
+	weekdaysRe   = regexp.MustCompile(`(?i)\b(?:every|on|each)?\s*weekdays?\b`)
+	weekendsRe   = regexp.MustCompile(`(?i)\b(?:every|on|each)?\s*weekends?\b`)
+	dayListRe    = regexp.MustCompile(`(?i)\b(every|each|on)\s+(` + weekdayPattern + `s?(?:\s*(?:,|/|&|\band\b)?\s*` + weekdayPattern + `s?)*)\b`)
+	weekdayRe    = regexp.MustCompile(`(?i)` + weekdayPattern + `s?`)
+	relativeRe   = regexp.MustCompile(`(?i)\b(today|tonight|tomorrow)\b`)
+	isoDateRe    = regexp.MustCompile(`(?i)\b(?:on\s+)?(\d{4}-\d{2}-\d{2})\b`)
+	nextDayRe    = regexp.MustCompile(`(?i)\b(?:on\s+|next\s+)(` + weekdayPattern + `)\b`)
+	quantityRe   = regexp.MustCompile(`(?i)\b(\d+(?:\.\d+)?)\s*(k|km|kilometers?|mi|miles?|m|meters?|pages?|chapters?|minutes?|mins?|hours?|hrs?|reps?|push-?ups|sit-?ups|squats|steps|glass(?:es)?|cups?|liters?|litres?|l|times|words|portions|items)\b`)
+	avoidRe      = regexp.MustCompile(`(?i)^(?:(?:avoid|quit|don't|dont|never)\b|no\s+\w|stop\s+\w+ing\b)`)
+	whitespaceRe = regexp.MustCompile(`\s+`)
+)
+
+// unitAliases normalizes units that are commonly abbreviated
+var unitAliases = map[string]string{
+	"k": "km", "kilometer": "km", "kilometers": "km",
+	"mi": "miles", "mile": "miles",
+	"meter": "m", "meters": "m",
+	"page": "pages", "chapter": "chapters",
+	"minute": "minutes", "min": "minutes", "mins": "minutes",
+	"hour": "hours", "hr": "hours", "hrs": "hours",
+	"rep": "reps", "pushups": "push-ups", "situps": "sit-ups",
+	"glass": "glasses", "cup": "cups",
+	"liter": "liters", "litre": "liters", "litres": "liters", "l": "liters",
+}
+
+// Parse interprets quick-add text relative to now
+func Parse(input string, now time.Time) (Result, error) {
+	text := " " + strings.TrimSpace(input) + " "
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	result := Result{Kind: KindTask, Type: TypeBuild}
+
+	// Time of day
+	if match := timeRe.FindStringSubmatch(text); match != nil {
+		clock, err := parseClock(match)
+		if err != nil {
+			return Result{}, err
+		}
+		result.Time = clock
+		text = timeRe.ReplaceAllString(text, " ")
+	}
+
+	// Start of a recurrence
+	var start *time.Time
+	if match := startRe.FindStringSubmatch(text); match != nil {
+		day, err := resolveDay(match[1], today)
+		if err != nil {
+			return Result{}, err
+		}
+		start = &day
+		text = strings.Replace(text, match[0], " ", 1)
+	}
+
+	// Recurrence
+	switch {
+	case dailyRe.MatchString(text):
+		result.Kind = KindHabit
+		text = dailyRe.ReplaceAllString(text, " ")
+	case weekdaysRe.MatchString(text):
+		result.Kind = KindHabit
+		result.Weekdays = []int{1, 2, 3, 4, 5}
+		text = weekdaysRe.ReplaceAllString(text, " ")
+	case weekendsRe.MatchString(text):
+		result.Kind = KindHabit
+		result.Weekdays = []int{0, 6}
+		text = weekendsRe.ReplaceAllString(text, " ")
+	default:
+		if match := dayListRe.FindStringSubmatch(text); match != nil {
+			days := weekdayRe.FindAllString(match[2], -1)
+			// "on friday" is a single date; "every friday" or "on fridays" recur
+			recurring := !strings.EqualFold(match[1], "on") || len(days) > 1 || isPluralWeekday(days[0])
+			if recurring {
+				result.Kind = KindHabit
+				result.Weekdays = parseWeekdays(days)
+				text = strings.Replace(text, match[0], " ", 1)
+			}
+		}
+	}
+
+	// One-off date
+	if result.Kind == KindTask {
+		day := today
+		switch {
+		case relativeRe.MatchString(text):
+			day, _ = resolveDay(relativeRe.FindStringSubmatch(text)[1], today)
+			text = relativeRe.ReplaceAllString(text, " ")
+		case isoDateRe.MatchString(text):
+			parsed, err := resolveDay(isoDateRe.FindStringSubmatch(text)[1], today)
+			if err != nil {
+				return Result{}, err
+			}
+			day = parsed
+			text = isoDateRe.ReplaceAllString(text, " ")
+		case nextDayRe.MatchString(text):
+			day = nextWeekday(today, weekdayIndex(nextDayRe.FindStringSubmatch(text)[1]))
+			text = nextDayRe.ReplaceAllString(text, " ")
+		}
+		if start != nil {
+			day = *start
+		}
+		result.Date = day.Format(dateLayout)
+	} else {
+		startDay := today
+		if start != nil {
+			startDay = *start
+		}
+		result.StartDate = startDay.Format(dateLayout)
+	}
+
+	// Quantity stays in the name but is also extracted as a target
+	if match := quantityRe.FindStringSubmatch(text); match != nil {
+		target, err := strconv.ParseFloat(match[1], 64)
+		if err == nil {
+			result.Target = target
+			result.Unit = normalizeUnit(match[2])
+		}
+	}
+
+	name := strings.Trim(whitespaceRe.ReplaceAllString(text, " "), " ,.;-")
+	if name == "" {
+		return Result{}, ErrEmptyName
+	}
+	if avoidRe.MatchString(name) {
+		result.Type = TypeAvoid
+		result.Target = 0
+		result.Unit = ""
+		if result.Kind == KindTask {
+			// Abstaining is ongoing by nature, so a date is when it starts
+			result.Kind = KindHabit
+			result.StartDate = result.Date
+			result.Date = ""
+		}
+	}
+	if result.Kind == KindTask {
+		result.Type = ""
+	}
+	first, size := utf8.DecodeRuneInString(name)
+	result.Name = string(unicode.ToUpper(first)) + name[size:]
+
+	return result, nil
+}
+
+// parseClock turns a time match into HH:MM
+func parseClock(match []string) (string, error) {
+	switch {
+	case match[6] != "":
+		if strings.EqualFold(match[6], "noon") {
+			return "12:00", nil
+		}
+		return "00:00", nil
+	case match[4] != "":
+		hour, _ := strconv.Atoi(match[4])
+		minute, _ := strconv.Atoi(match[5])
+		if hour > 23 || minute > 59 {
+			return "", fmt.Errorf("invalid time %s:%s", match[4], match[5])
+		}
+		return fmt.Sprintf("%02d:%02d", hour, minute), nil
+	}
+
+	hour, _ := strconv.Atoi(match[1])
+	minute := 0
+	if match[2] != "" {
+		minute, _ = strconv.Atoi(match[2])
+	}
+	if hour < 1 || hour > 12 || minute > 59 {
+		return "", fmt.Errorf("invalid time %s", strings.TrimSpace(match[0]))
+	}
+	if hour == 12 {
+		hour = 0
+	}
+	if strings.EqualFold(match[3], "pm") {
+		hour += 12
+	}
+	return fmt.Sprintf("%02d:%02d", hour, minute), nil
+}
+
+// resolveDay resolves today, tomorrow, a weekday or a YYYY-MM-DD date
+func resolveDay(value string, today time.Time) (time.Time, error) {
+	value = strings.ToLower(whitespaceRe.ReplaceAllString(strings.TrimSpace(value), " "))
+	switch value {
+	case "today", "tonight":
+		return today, nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), nil
+	}
+	if strings.HasPrefix(value, "next ") {
+		return nextWeekday(today, weekdayIndex(strings.TrimPrefix(value, "next "))), nil
+	}
+	if index := weekdayIndex(value); index >= 0 {
+		return nextWeekday(today, index), nil
+	}
+
+	day, err := time.ParseInLocation(dateLayout, value, today.Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+	return day, nil
+}
+
+// nextWeekday returns the first day after today falling on the given weekday
+func nextWeekday(today time.Time, weekday int) time.Time {
+	days := (weekday - int(today.Weekday()) + 7) % 7
+	if days == 0 {
+		days = 7
+	}
+	return today.AddDate(0, 0, days)
+}
+
+// weekdayIndex maps a weekday name or abbreviation to 0 (Sunday) - 6
+func weekdayIndex(name string) int {
+	name = strings.ToLower(name)
+	prefixes := []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+	for index, prefix := range prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return index
+		}
+	}
+	return -1
+}
+
+// isPluralWeekday reports whether a weekday is written as a plural ("mondays"),
+// leaving out abbreviations that end in s ("tues", "thurs")
+func isPluralWeekday(name string) bool {
+	name = strings.ToLower(name)
+	return strings.HasSuffix(name, "s") && name != "tues" && name != "thurs"
+}
+
+// parseWeekdays converts weekday names to a sorted, de-duplicated schedule
+func parseWeekdays(names []string) []int {
+	seen := [7]bool{}
+	for _, name := range names {
+		if index := weekdayIndex(name); index >= 0 {
+			seen[index] = true
+		}
+	}
+	weekdays := []int{}
+	for index, ok := range seen {
+		if ok {
+			weekdays = append(weekdays, index)
+		}
+	}
+	return weekdays
+}
+
+// normalizeUnit lowercases a unit and expands common abbreviations
+func normalizeUnit(unit string) string {
+	unit = strings.ToLower(unit)
+	if alias, ok := unitAliases[unit]; ok {
+		return alias
+	}
+	return unit
+}
@@ -0,0 +1,65 @@
+package parser
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// now is a Wednesday
+var now = time.Date(2024, 5, 15, 10, 0, 0, 0, time.UTC)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Result
+	}{
+		{"run 5k every Mon Wed Fri at 7am", Result{Kind: KindHabit, Name: "Run 5k", Type: TypeBuild, StartDate: "2024-05-15", Weekdays: []int{1, 3, 5}, Time: "07:00", Target: 5, Unit: "km"}},
+		{"read 20 pages daily starting tomorrow", Result{Kind: KindHabit, Name: "Read 20 pages", Type: TypeBuild, StartDate: "2024-05-16", Target: 20, Unit: "pages"}},
+		{"meditate 10 min every day at 19:00", Result{Kind: KindHabit, Name: "Meditate 10 min", Type: TypeBuild, StartDate: "2024-05-15", Time: "19:00", Target: 10, Unit: "minutes"}},
+		{"walk 2.5 miles each day", Result{Kind: KindHabit, Name: "Walk 2.5 miles", Type: TypeBuild, StartDate: "2024-05-15", Target: 2.5, Unit: "miles"}},
+		{"drink 8 glasses of water daily", Result{Kind: KindHabit, Name: "Drink 8 glasses of water", Type: TypeBuild, StartDate: "2024-05-15", Target: 8, Unit: "glasses"}},
+		{"sleep by 10pm weekdays", Result{Kind: KindHabit, Name: "Sleep", Type: TypeBuild, StartDate: "2024-05-15", Weekdays: []int{1, 2, 3, 4, 5}, Time: "22:00"}},
+		{"clean the house weekends", Result{Kind: KindHabit, Name: "Clean the house", Type: TypeBuild, StartDate: "2024-05-15", Weekdays: []int{0, 6}}},
+		{"yoga on fridays", Result{Kind: KindHabit, Name: "Yoga", Type: TypeBuild, StartDate: "2024-05-15", Weekdays: []int{5}}},
+		{"swim every tue and thu", Result{Kind: KindHabit, Name: "Swim", Type: TypeBuild, StartDate: "2024-05-15", Weekdays: []int{2, 4}}},
+		{"gym starting next monday every mon/wed", Result{Kind: KindHabit, Name: "Gym", Type: TypeBuild, StartDate: "2024-05-20", Weekdays: []int{1, 3}}},
+		{"lunch with Sam at noon every day", Result{Kind: KindHabit, Name: "Lunch with Sam", Type: TypeBuild, StartDate: "2024-05-15", Time: "12:00"}},
+		{"buy milk", Result{Kind: KindTask, Name: "Buy milk", Date: "2024-05-15"}},
+		{"buy milk tomorrow at 5pm", Result{Kind: KindTask, Name: "Buy milk", Date: "2024-05-16", Time: "17:00"}},
+		{"call mom tomorrow", Result{Kind: KindTask, Name: "Call mom", Date: "2024-05-16"}},
+		{"dentist on friday", Result{Kind: KindTask, Name: "Dentist", Date: "2024-05-17"}},
+		{"pay rent 2024-06-01", Result{Kind: KindTask, Name: "Pay rent", Date: "2024-06-01"}},
+		{"no sugar", Result{Kind: KindHabit, Name: "No sugar", Type: TypeAvoid, StartDate: "2024-05-15"}},
+		{"quit smoking", Result{Kind: KindHabit, Name: "Quit smoking", Type: TypeAvoid, StartDate: "2024-05-15"}},
+		{"stop smoking starting monday", Result{Kind: KindHabit, Name: "Stop smoking", Type: TypeAvoid, StartDate: "2024-05-20"}},
+		{"stop biting nails", Result{Kind: KindHabit, Name: "Stop biting nails", Type: TypeAvoid, StartDate: "2024-05-15"}},
+		{"stop by the pharmacy tomorrow", Result{Kind: KindTask, Name: "Stop by the pharmacy", Date: "2024-05-16"}},
+		{"éat cake daily", Result{Kind: KindHabit, Name: "Éat cake", Type: TypeBuild, StartDate: "2024-05-15"}},
+		{"no alcohol on 2024-06-01", Result{Kind: KindHabit, Name: "No alcohol", Type: TypeAvoid, StartDate: "2024-06-01"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			got, err := Parse(test.input, now)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", test.input, err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Parse(%q) = %+v, want %+v", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	if _, err := Parse("at 7am", now); !errors.Is(err, ErrEmptyName) {
+		t.Errorf("Parse(%q) error = %v, want ErrEmptyName", "at 7am", err)
+	}
+	for _, input := range []string{"wake up at 13pm daily", "stretch at 25:00 daily", "pay rent 2024-02-30"} {
+		if _, err := Parse(input, now); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", input)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package points
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"habit-tracker/server/db"
+	"habit-tracker/server/models"
+	"habit-tracker/server/streaks"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RulesVersion identifies the scoring rules below. Bump it whenever they change
+// and recompute users' points so the ledger reflects the new rules.
+const RulesVersion = 2
+
+// DifficultyPoints is the base number of points a completion earns for each
+// habit difficulty. Tasks without a habit count as medium.
+var DifficultyPoints = map[string]int{
+	models.DifficultyEasy:   10,
+	models.DifficultyMedium: 20,
+	models.DifficultyHard:   30,
+}
+
+// Every day of the streak held before a completion adds streakBonusPerDay to
+// its multiplier, up to maxStreakBonusDays days (a 2x multiplier).
+const (
+	streakBonusPerDay  = 0.1
+	maxStreakBonusDays = 10
+)
+
+// dateLayout is the YYYY-MM-DD format used for task dates
+const dateLayout = "2006-01-02"
+
+// Award describes what a task is worth under the current rules
+type Award struct {
+	Points     int
+	Difficulty string
+	Multiplier float64
+}
+
+// LevelInfo describes a user's level and how far they are into it
+type LevelInfo struct {
+	XP           int `json:"xp"`
+	Level        int `json:"level"`
+	LevelStartXP int `json:"level_start_xp"`
+	NextLevelXP  int `json:"next_level_xp"`
+}
+
+// levelThreshold is the XP needed to reach a level: 0, 100, 300, 600, 1000...
+func levelThreshold(level int) int {
+	return 50 * level * (level - 1)
+}
+
+// Level returns the level reached with the given XP
+func Level(xp int) LevelInfo {
+	level := 1
+	for xp >= levelThreshold(level+1) {
+		level++
+	}
+	return LevelInfo{
+		XP:           xp,
+		Level:        level,
+		LevelStartXP: levelThreshold(level),
+		NextLevelXP:  levelThreshold(level + 1),
+	}
+}
+
+// History is what scores are calculated from: a user's tasks outside the
+// trash and all of their habits
+type History struct {
+	Tasks  []models.Task
+	Habits []models.Habit
+}
+
+// LoadHistory loads a user's history
+func LoadHistory(userID primitive.ObjectID) (History, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := db.TaskColl.Find(ctx, bson.M{"user_id": userID, "deleted_at": nil})
+	if err != nil {
+		return History{}, err
+	}
+	var history History
+	if err := cursor.All(ctx, &history.Tasks); err != nil {
+		return History{}, err
+	}
+
+	cursor, err = db.HabitColl.Find(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return History{}, err
+	}
+	if err := cursor.All(ctx, &history.Habits); err != nil {
+		return History{}, err
+	}
+	return history, nil
+}
+
+// scoring is a history prepared for Calculate
+type scoring struct {
+	days         map[string]streaks.DayInfo
+	excuses      streaks.Excuses
+	difficulties map[primitive.ObjectID]string
+}
+
+// prepare groups a history by day the way streaks are counted, leaving out
+// paused and archived habits, and indexes the difficulty of each habit
+func prepare(history History) scoring {
+	var habits []models.Habit
+	for _, habit := range history.Habits {
+		if habit.DeletedAt == nil {
+			habits = append(habits, habit)
+		}
+	}
+	inactive, excuses := streaks.Inactive(habits)
+
+	difficulties := make(map[primitive.ObjectID]string, len(history.Habits))
+	for _, habit := range history.Habits {
+		difficulties[habit.ID] = habit.Difficulty
+	}
+	return scoring{
+		days:         streaks.GroupByDate(streaks.ExcludeHabitTasks(history.Tasks, inactive)),
+		excuses:      excuses,
+		difficulties: difficulties,
+	}
+}
+
+// Calculate returns what a task is worth. Incomplete, deleted and frozen tasks
+// are worth nothing; a completion earns the base points of its difficulty times
+// the streak multiplier of the day before the task.
+func Calculate(task models.Task, difficulty string, days map[string]streaks.DayInfo, excuses streaks.Excuses) Award {
+	if difficulty == "" {
+		difficulty = models.DifficultyMedium
+	}
+	award := Award{Difficulty: difficulty, Multiplier: 1}
+	if !task.Completed || task.DeletedAt != nil || streaks.IsFrozenTask(task) || len(task.Date) < len(dateLayout) {
+		return award
+	}
+
+	day, err := time.Parse(dateLayout, task.Date[:len(dateLayout)])
+	if err != nil {
+		return award
+	}
+
+	streak := streaks.EndingOn(days, excuses, day.AddDate(0, 0, -1))
+	if streak > maxStreakBonusDays {
+		streak = maxStreakBonusDays
+	}
+	award.Multiplier = 1 + float64(streak)*streakBonusPerDay
+	award.Points = int(math.Round(float64(DifficultyPoints[difficulty]) * award.Multiplier))
+	return award
+}
+
+// SyncTask brings the ledger of a task in line with what the task is worth
+// now, given the user's history. When they differ it writes an adjustment
+// entry and updates the user's XP, and returns the entry; otherwise it returns
+// nil.
+func SyncTask(taskID primitive.ObjectID, history History) (*models.PointsEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var task models.Task
+	if err := db.TaskColl.FindOne(ctx, bson.M{"_id": taskID}).Decode(&task); err != nil {
+		return nil, err
+	}
+
+	scores := prepare(history)
+	award := Calculate(task, taskDifficulty(task, scores.difficulties), scores.days, scores.excuses)
+	return settle(ctx, task, award, "", nil)
+}
+
+// Recompute re-scores every task of a user under the current rules, writes an
+// adjustment entry for each task whose points changed, and resets the user's XP
+// to the ledger total. It returns the number of adjustments written.
+func Recompute(userID primitive.ObjectID, history History) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	// Deleted tasks are included so points they still hold are taken back
+	cursor, err := db.TaskColl.Find(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return 0, err
+	}
+	var tasks []models.Task
+	if err := cursor.All(ctx, &tasks); err != nil {
+		return 0, err
+	}
+
+	totals, err := ledgerTotals(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return 0, err
+	}
+
+	scores := prepare(history)
+	adjustments := 0
+	for _, task := range tasks {
+		award := Calculate(task, taskDifficulty(task, scores.difficulties), scores.days, scores.excuses)
+		total := totals[task.ID]
+		entry, err := settle(ctx, task, award, models.PointsReasonRecomputed, &total)
+		if err != nil {
+			return adjustments, err
+		}
+		if entry != nil {
+			adjustments++
+		}
+	}
+
+	// Reset the cached XP in case earlier updates were lost
+	total, err := ledgerTotal(ctx, userID)
+	if err != nil {
+		return adjustments, err
+	}
+	_, err = db.UserColl.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{"$set": bson.M{"xp": total}})
+	return adjustments, err
+}
+
+// maxSettleAttempts bounds how often settle retries after losing a race
+const maxSettleAttempts = 3
+
+// settle records the adjustment that brings a task's ledger to award, if any.
+// Each entry takes the next seq of its task, so when a concurrent settle of the
+// same task wins, the insert fails on the unique index instead of awarding
+// twice, and settle retries against the new total. known is the task's ledger
+// total if already read. An empty reason is chosen from the adjustment's sign.
+func settle(ctx context.Context, task models.Task, award Award, reason string, known *taskTotal) (*models.PointsEntry, error) {
+	for attempt := 0; ; attempt++ {
+		var total taskTotal
+		if known != nil && attempt == 0 {
+			total = *known
+		} else {
+			totals, err := ledgerTotals(ctx, bson.M{"task_id": task.ID})
+			if err != nil {
+				return nil, err
+			}
+			total = totals[task.ID]
+		}
+
+		delta := award.Points - total.Points
+		if delta == 0 {
+			return nil, nil
+		}
+
+		entryReason := reason
+		if entryReason == "" {
+			entryReason = models.PointsReasonCompleted
+			if delta < 0 {
+				entryReason = models.PointsReasonUncompleted
+			}
+		}
+
+		entry, err := recordEntry(ctx, task, award, delta, entryReason, total.Entries+1)
+		if mongo.IsDuplicateKeyError(err) && attempt+1 < maxSettleAttempts {
+			continue
+		}
+		return entry, err
+	}
+}
+
+// recordEntry appends a ledger entry and adds its points to the user's XP
+func recordEntry(ctx context.Context, task models.Task, award Award, delta int, reason string, seq int) (*models.PointsEntry, error) {
+	entry := models.PointsEntry{
+		UserID:       task.UserID,
+		TaskID:       task.ID,
+		Seq:          seq,
+		Points:       delta,
+		Reason:       reason,
+		Difficulty:   award.Difficulty,
+		Multiplier:   award.Multiplier,
+		RulesVersion: RulesVersion,
+		CreatedAt:    time.Now(),
+	}
+
+	result, err := db.PointsColl.InsertOne(ctx, entry)
+	if err != nil {
+		return nil, err
+	}
+	entry.ID = result.InsertedID.(primitive.ObjectID)
+
+	if _, err := db.UserColl.UpdateOne(ctx, bson.M{"_id": task.UserID}, bson.M{"$inc": bson.M{"xp": delta}}); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// taskDifficulty returns the difficulty of a task's habit
+func taskDifficulty(task models.Task, difficulties map[primitive.ObjectID]string) string {
+	if task.HabitID == nil {
+		return models.DifficultyMedium
+	}
+	return difficulties[*task.HabitID]
+}
+
+// taskTotal is the sum and number of the ledger entries of a task
+type taskTotal struct {
+	Points  int
+	Entries int
+}
+
+// ledgerTotals sums the ledger entries matching filter per task
+func ledgerTotals(ctx context.Context, filter bson.M) (map[primitive.ObjectID]taskTotal, error) {
+	cursor, err := db.PointsColl.Aggregate(ctx, bson.A{
+		bson.M{"$match": filter},
+		bson.M{"$group": bson.M{
+			"_id":     "$task_id",
+			"points":  bson.M{"$sum": "$points"},
+			"entries": bson.M{"$sum": 1},
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		TaskID  primitive.ObjectID `bson:"_id"`
+		Points  int                `bson:"points"`
+		Entries int                `bson:"entries"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	totals := make(map[primitive.ObjectID]taskTotal, len(rows))
+	for _, row := range rows {
+		totals[row.TaskID] = taskTotal{Points: row.Points, Entries: row.Entries}
+	}
+	return totals, nil
+}
+
+// ledgerTotal sums all of a user's ledger entries
+func ledgerTotal(ctx context.Context, userID primitive.ObjectID) (int, error) {
+	totals, err := ledgerTotals(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, task := range totals {
+		total += task.Points
+	}
+	return total, nil
+}
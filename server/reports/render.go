@@ -0,0 +1,142 @@
+package reports
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+
+	"habit-tracker/server/models"
+)
+
+// title returns a report's heading, e.g. "Weekly review: 2024-05-06 to 2024-05-12"
+func title(report models.Report) string {
+	kind := "Weekly"
+	if report.Period == models.ReportMonth {
+		kind = "Monthly"
+	}
+	return fmt.Sprintf("%s review: %s to %s", kind, report.StartDate, report.EndDate)
+}
+
+// percent formats a rate between 0 and 1 as a percentage
+func percent(rate float64) string {
+	return fmt.Sprintf("%.1f%%", rate*100)
+}
+
+// rateChange describes the change in completion rate against the previous period
+func rateChange(report models.Report) string {
+	points := report.RateChange * 100
+	switch {
+	case report.Previous.Tasks == 0:
+		return "no tasks in the previous " + report.Period
+	case points > 0:
+		return fmt.Sprintf("up %.1f points from %s the previous %s", points, percent(report.Previous.Rate), report.Period)
+	case points < 0:
+		return fmt.Sprintf("down %.1f points from %s the previous %s", -points, percent(report.Previous.Rate), report.Period)
+	}
+	return fmt.Sprintf("the same as the previous %s", report.Period)
+}
+
+// habitLine describes a habit's consistency, e.g. "Read: 6/7 (85.7%)"
+func habitLine(habit models.ReportHabit) string {
+	name := habit.Name
+	if name == "" {
+		name = "Deleted habit"
+	}
+	return fmt.Sprintf("%s: %d/%d (%s)", name, habit.Completed, habit.Tasks, percent(habit.Rate))
+}
+
+// noteLine describes a note, e.g. "2024-05-07, Run: felt great"
+func noteLine(note models.ReportNote) string {
+	prefix := note.Date
+	if note.Name != "" {
+		prefix += ", " + note.Name
+	}
+	if note.Source == "relapse" {
+		prefix += " (relapse)"
+	}
+	return fmt.Sprintf("%s: %s", prefix, note.Text)
+}
+
+// sections returns the report as headed lists of lines, shared by both renderers
+func sections(report models.Report) []section {
+	streak := fmt.Sprintf("%d days going in, %d days at the end (%+d); longest this %s: %d days",
+		report.Streak.Start, report.Streak.End, report.Streak.Change, report.Period, report.Streak.Longest)
+
+	result := []section{
+		{Heading: "Completion", Lines: []string{
+			fmt.Sprintf("Completed %d of %d tasks (%s), %s", report.Completion.Completed, report.Completion.Tasks,
+				percent(report.Completion.Rate), rateChange(report)),
+			fmt.Sprintf("Perfect days: %d", report.Completion.PerfectDays),
+		}},
+		{Heading: "Streak", Lines: []string{streak}},
+	}
+
+	for _, ranked := range []struct {
+		heading string
+		habits  []models.ReportHabit
+	}{
+		{"Most consistent habits", report.MostConsistent},
+		{"Least consistent habits", report.LeastConsistent},
+	} {
+		if len(ranked.habits) == 0 {
+			continue
+		}
+		lines := make([]string, 0, len(ranked.habits))
+		for _, habit := range ranked.habits {
+			lines = append(lines, habitLine(habit))
+		}
+		result = append(result, section{Heading: ranked.heading, Lines: lines})
+	}
+
+	if len(report.Notes) > 0 {
+		lines := make([]string, 0, len(report.Notes))
+		for _, note := range report.Notes {
+			lines = append(lines, noteLine(note))
+		}
+		result = append(result, section{Heading: "Notes", Lines: lines})
+	}
+	return result
+}
+
+// section is a heading with its list items
+type section struct {
+	Heading string
+	Lines   []string
+}
+
+// Markdown renders a report as Markdown
+func Markdown(report models.Report) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", title(report))
+	for _, s := range sections(report) {
+		fmt.Fprintf(&b, "\n## %s\n\n", s.Heading)
+		for _, line := range s.Lines {
+			fmt.Fprintf(&b, "- %s\n", line)
+		}
+	}
+	return b.String()
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body>
+<h1>{{.Title}}</h1>
+{{range .Sections}}<h2>{{.Heading}}</h2>
+<ul>
+{{range .Lines}}<li>{{.}}</li>
+{{end}}</ul>
+{{end}}</body>
+</html>
+`))
+
+// HTML renders a report as a standalone HTML page. Notes are escaped.
+func HTML(report models.Report) (string, error) {
+	var b bytes.Buffer
+	err := htmlTemplate.Execute(&b, struct {
+		Title    string
+		Sections []section
+	}{title(report), sections(report)})
+	return b.String(), err
+}
@@ -0,0 +1,249 @@
+// Package reports summarizes a user's week or month for review: completion
+// against the previous period, streak changes, the most and least consistent
+// habits and the notes written along the way.
+package reports
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"habit-tracker/server/db"
+	"habit-tracker/server/models"
+	"habit-tracker/server/streaks"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// dateLayout is the YYYY-MM-DD format used for task dates
+const dateLayout = "2006-01-02"
+
+// rankedHabits is how many habits the most and least consistent lists hold
+const rankedHabits = 3
+
+// Period is a reporting window of whole days. Weeks run Monday to Sunday.
+type Period struct {
+	Kind  string
+	Start time.Time
+	End   time.Time
+}
+
+// PeriodFor returns the week or month containing day
+func PeriodFor(kind string, day time.Time) (Period, error) {
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	switch kind {
+	case models.ReportWeek:
+		start := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		return Period{Kind: kind, Start: start, End: start.AddDate(0, 0, 6)}, nil
+	case models.ReportMonth:
+		start := day.AddDate(0, 0, 1-day.Day())
+		return Period{Kind: kind, Start: start, End: start.AddDate(0, 1, -1)}, nil
+	}
+	return Period{}, fmt.Errorf("unknown report period %q", kind)
+}
+
+// Previous returns the period right before p
+func (p Period) Previous() Period {
+	previous, _ := PeriodFor(p.Kind, p.Start.AddDate(0, 0, -1))
+	return previous
+}
+
+// contains reports whether a YYYY-MM-DD day falls in the period
+func (p Period) contains(day string) bool {
+	return day >= p.Start.Format(dateLayout) && day <= p.End.Format(dateLayout)
+}
+
+// Generate builds a user's report for a period from their task history. now
+// decides whether the period is still in progress, in which case the closing
+// streak is the current one.
+func Generate(userID primitive.ObjectID, period Period, now time.Time) (models.Report, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cursor, err := db.TaskColl.Find(ctx, bson.M{"user_id": userID, "deleted_at": nil})
+	if err != nil {
+		return models.Report{}, err
+	}
+	var tasks []models.Task
+	if err := cursor.All(ctx, &tasks); err != nil {
+		return models.Report{}, err
+	}
+
+	// Deleted habits are included so their tasks keep a name
+	cursor, err = db.HabitColl.Find(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return models.Report{}, err
+	}
+	var habits []models.Habit
+	if err := cursor.All(ctx, &habits); err != nil {
+		return models.Report{}, err
+	}
+	names := make(map[primitive.ObjectID]string, len(habits))
+	for _, habit := range habits {
+		names[habit.ID] = habit.Name
+	}
+
+	cursor, err = db.RelapseColl.Find(ctx, bson.M{
+		"user_id": userID,
+		"date":    bson.M{"$gte": period.Start.Format(dateLayout), "$lte": period.End.Format(dateLayout)},
+		"note":    bson.M{"$nin": bson.A{nil, ""}},
+	})
+	if err != nil {
+		return models.Report{}, err
+	}
+	var relapses []models.Relapse
+	if err := cursor.All(ctx, &relapses); err != nil {
+		return models.Report{}, err
+	}
+
+	inactive, excuses := streaks.Inactive(habits)
+	days := streaks.GroupByDate(streaks.ExcludeHabitTasks(tasks, inactive))
+
+	report := models.Report{
+		UserID:      userID,
+		Period:      period.Kind,
+		StartDate:   period.Start.Format(dateLayout),
+		EndDate:     period.End.Format(dateLayout),
+		Completion:  completion(tasks, days, period),
+		Previous:    completion(tasks, days, period.Previous()),
+		Streak:      streakChange(days, excuses, period, now),
+		Notes:       notes(tasks, relapses, names, period),
+		GeneratedAt: time.Now(),
+	}
+	report.RateChange = round(report.Completion.Rate - report.Previous.Rate)
+	report.MostConsistent, report.LeastConsistent = rankHabits(tasks, names, period)
+
+	return report, nil
+}
+
+// completion counts the tasks of a period; frozen-day markers are not tasks
+func completion(tasks []models.Task, days map[string]streaks.DayInfo, period Period) models.ReportCompletion {
+	var result models.ReportCompletion
+	for _, task := range tasks {
+		if !period.contains(day(task)) || streaks.IsFrozenTask(task) {
+			continue
+		}
+		result.Tasks++
+		if task.Completed {
+			result.Completed++
+		}
+	}
+	result.Rate = rate(result.Completed, result.Tasks)
+
+	for date := period.Start; !date.After(period.End); date = date.AddDate(0, 0, 1) {
+		if days[date.Format(dateLayout)].Perfect() {
+			result.PerfectDays++
+		}
+	}
+	return result
+}
+
+// streakChange compares the streak going into a period with the streak at its
+// end, or the current streak while the period is in progress
+func streakChange(days map[string]streaks.DayInfo, excuses streaks.Excuses, period Period, now time.Time) models.ReportStreak {
+	result := models.ReportStreak{Start: streaks.EndingOn(days, excuses, period.Start.AddDate(0, 0, -1))}
+	if period.End.Format(dateLayout) >= now.Format(dateLayout) {
+		result.End = streaks.Current(days, excuses, now)
+	} else {
+		result.End = streaks.EndingOn(days, excuses, period.End)
+	}
+	result.Change = result.End - result.Start
+
+	run := 0
+	for date := period.Start; !date.After(period.End); date = date.AddDate(0, 0, 1) {
+		info := days[date.Format(dateLayout)]
+		switch {
+		case info.Perfect():
+			run++
+		case info.Frozen:
+		default:
+			run = 0
+		}
+		if run > result.Longest {
+			result.Longest = run
+		}
+	}
+	return result
+}
+
+// rankHabits returns the habits with the highest and the lowest completion
+// rates in a period. A habit shows up in at most one of the lists.
+func rankHabits(tasks []models.Task, names map[primitive.ObjectID]string, period Period) ([]models.ReportHabit, []models.ReportHabit) {
+	byHabit := make(map[primitive.ObjectID]*models.ReportHabit)
+	for _, task := range tasks {
+		if task.HabitID == nil || !period.contains(day(task)) {
+			continue
+		}
+		habit, ok := byHabit[*task.HabitID]
+		if !ok {
+			habit = &models.ReportHabit{HabitID: *task.HabitID, Name: names[*task.HabitID]}
+			byHabit[*task.HabitID] = habit
+		}
+		habit.Tasks++
+		if task.Completed {
+			habit.Completed++
+		}
+	}
+
+	ranked := make([]models.ReportHabit, 0, len(byHabit))
+	for _, habit := range byHabit {
+		habit.Rate = rate(habit.Completed, habit.Tasks)
+		ranked = append(ranked, *habit)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Rate != ranked[j].Rate {
+			return ranked[i].Rate > ranked[j].Rate
+		}
+		if ranked[i].Tasks != ranked[j].Tasks {
+			return ranked[i].Tasks > ranked[j].Tasks
+		}
+		return ranked[i].Name < ranked[j].Name
+	})
+
+	most := ranked[:min(rankedHabits, len(ranked))]
+	rest := ranked[len(most):]
+	least := make([]models.ReportHabit, 0, rankedHabits)
+	for i := len(rest) - 1; i >= 0 && len(least) < rankedHabits; i-- {
+		least = append(least, rest[i])
+	}
+	return append([]models.ReportHabit{}, most...), least
+}
+
+// notes collects the task and relapse notes of a period in date order
+func notes(tasks []models.Task, relapses []models.Relapse, names map[primitive.ObjectID]string, period Period) []models.ReportNote {
+	result := []models.ReportNote{}
+	for _, task := range tasks {
+		if task.Note != "" && period.contains(day(task)) {
+			result = append(result, models.ReportNote{Date: day(task), Source: "task", Name: task.Name, Text: task.Note})
+		}
+	}
+	for _, relapse := range relapses {
+		result = append(result, models.ReportNote{Date: relapse.Date, Source: "relapse", Name: names[relapse.HabitID], Text: relapse.Note})
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Date < result[j].Date })
+	return result
+}
+
+// day returns the YYYY-MM-DD part of a task date
+func day(task models.Task) string {
+	if len(task.Date) >= len(dateLayout) {
+		return task.Date[:len(dateLayout)]
+	}
+	return task.Date
+}
+
+// rate returns part/total rounded to four decimal places, or 0 when total is 0
+func rate(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return round(float64(part) / float64(total))
+}
+
+// round rounds to four decimal places
+func round(value float64) float64 {
+	return math.Round(value*10000) / 10000
+}
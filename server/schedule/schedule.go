@@ -0,0 +1,86 @@
+// Package schedule answers when things happen for a user: which days a habit
+// is due, what a user's local time is and whether they are in quiet hours.
+package schedule
+
+import (
+	"fmt"
+	"time"
+	// Embed the time zone database so user time zones resolve on any host
+	_ "time/tzdata"
+
+	"habit-tracker/server/models"
+)
+
+// DateLayout is the YYYY-MM-DD format used for task and habit dates
+const DateLayout = "2006-01-02"
+
+// ClockLayout is the HH:MM format used for reminder times and quiet hours
+const ClockLayout = "15:04"
+
+// ParseClock parses an HH:MM time of day into minutes after midnight
+func ParseClock(value string) (int, error) {
+	clock, err := time.Parse(ClockLayout, value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", value)
+	}
+	return clock.Hour()*60 + clock.Minute(), nil
+}
+
+// Location returns a user's time zone, falling back to the server's
+func Location(settings models.UserSettings) *time.Location {
+	if settings.Timezone == "" {
+		return time.Local
+	}
+	location, err := time.LoadLocation(settings.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return location
+}
+
+// At returns the given HH:MM time on the calendar day of day, in day's location
+func At(day time.Time, clock string) (time.Time, error) {
+	minutes, err := ParseClock(clock)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), minutes/60, minutes%60, 0, 0, day.Location()), nil
+}
+
+// InQuietHours reports whether a local time falls in a user's quiet hours.
+// Quiet hours may wrap past midnight, e.g. 22:00 to 07:00.
+func InQuietHours(settings models.UserSettings, local time.Time) bool {
+	if settings.QuietHoursStart == "" || settings.QuietHoursEnd == "" {
+		return false
+	}
+	start, err := ParseClock(settings.QuietHoursStart)
+	if err != nil {
+		return false
+	}
+	end, err := ParseClock(settings.QuietHoursEnd)
+	if err != nil {
+		return false
+	}
+
+	minute := local.Hour()*60 + local.Minute()
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// IsScheduledOn reports whether a habit should have a task on the given day
+func IsScheduledOn(habit models.Habit, day time.Time) bool {
+	if day.Format(DateLayout) < habit.StartDate {
+		return false
+	}
+	if len(habit.Weekdays) == 0 {
+		return true
+	}
+	for _, weekday := range habit.Weekdays {
+		if time.Weekday(weekday) == day.Weekday() {
+			return true
+		}
+	}
+	return false
+}
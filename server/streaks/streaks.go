@@ -0,0 +1,215 @@
+package streaks
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"habit-tracker/server/models"
+	"habit-tracker/server/schedule"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// dateLayout is the YYYY-MM-DD format used for task dates
+const dateLayout = "2006-01-02"
+
+// DayInfo represents the completion status of tasks for a specific date
+type DayInfo struct {
+	Total     int
+	Completed int
+	Frozen    bool
+}
+
+// Perfect reports whether every non-frozen task of the day was completed
+func (info DayInfo) Perfect() bool {
+	return info.Total > 0 && !info.Frozen && info.Completed == info.Total
+}
+
+// IsFrozenTask reports whether a task marks its day as frozen
+func IsFrozenTask(task models.Task) bool {
+	return strings.Contains(strings.ToLower(task.Name), "frozen")
+}
+
+// GroupByDate organizes tasks by date and tracks their completion status
+func GroupByDate(tasks []models.Task) map[string]DayInfo {
+	dateTasks := make(map[string]DayInfo)
+
+	for _, task := range tasks {
+		if len(task.Date) < len(dateLayout) {
+			continue
+		}
+		dateStr := task.Date[:len(dateLayout)] // Get YYYY-MM-DD format
+		info := dateTasks[dateStr]
+		info.Total++
+
+		if IsFrozenTask(task) {
+			info.Frozen = true
+		} else if task.Completed {
+			info.Completed++
+		}
+		dateTasks[dateStr] = info
+	}
+
+	return dateTasks
+}
+
+// Current determines the current streak of completed tasks as of now.
+// A streak is maintained when all non-frozen tasks are completed for consecutive
+// days; frozen days keep the streak alive without adding to it.
+// Days without tasks that excuses covers are skipped rather than breaking the
+// streak, so pausing a habit does not count against the user.
+func Current(dateTasks map[string]DayInfo, excuses Excuses, now time.Time) int {
+	streak := 0
+	currentDate := now
+
+	// Check today's tasks
+	dateStr := currentDate.Format(dateLayout)
+	if info := dateTasks[dateStr]; !info.Frozen && info.Completed == info.Total {
+		streak++
+	}
+
+	// Check previous days
+	for {
+		currentDate = currentDate.AddDate(0, 0, -1)
+		dateStr := currentDate.Format(dateLayout)
+		info := dateTasks[dateStr]
+
+		if info.Total == 0 && excuses.Covers(currentDate) {
+			continue
+		}
+
+		// Break streak if no tasks or incomplete tasks
+		if info.Total == 0 || (!info.Frozen && info.Completed < info.Total) {
+			break
+		}
+
+		// Only count days where all tasks are completed
+		if !info.Frozen && info.Completed == info.Total {
+			streak++
+		}
+	}
+
+	return streak
+}
+
+// EndingOn returns the length of the streak that ends on the given day: the
+// run of perfect days up to and including it, bridged by frozen days and by
+// days without tasks that excuses covers
+func EndingOn(dateTasks map[string]DayInfo, excuses Excuses, day time.Time) int {
+	streak := 0
+	for {
+		info := dateTasks[day.Format(dateLayout)]
+		switch {
+		case info.Perfect():
+			streak++
+		case info.Frozen:
+		case info.Total == 0 && excuses.Covers(day):
+		default:
+			return streak
+		}
+		day = day.AddDate(0, 0, -1)
+	}
+}
+
+// Longest determines the longest streak found anywhere in the task history.
+// Unlike Current it takes no excuses, so a paused habit's empty days end a run.
+func Longest(dateTasks map[string]DayInfo) int {
+	days := SortedDays(dateTasks)
+	if len(days) == 0 {
+		return 0
+	}
+
+	first, _ := time.Parse(dateLayout, days[0])
+	last, _ := time.Parse(dateLayout, days[len(days)-1])
+
+	longest, run := 0, 0
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		info := dateTasks[day.Format(dateLayout)]
+		switch {
+		case info.Perfect():
+			run++
+		case info.Frozen:
+		default:
+			run = 0
+		}
+		if run > longest {
+			longest = run
+		}
+	}
+
+	return longest
+}
+
+// pause is a span of days on which a habit was paused. end is the first day
+// the habit was active again, or "" while it is still paused.
+type pause struct {
+	habit      models.Habit
+	start, end string
+}
+
+// Excuses lists the pauses that may keep a day without tasks from breaking a
+// streak
+type Excuses []pause
+
+// Covers reports whether a day falls inside the pause of a habit that was
+// scheduled on it
+func (excuses Excuses) Covers(day time.Time) bool {
+	date := day.Format(dateLayout)
+	for _, pause := range excuses {
+		if date < pause.start || (pause.end != "" && date >= pause.end) {
+			continue
+		}
+		if schedule.IsScheduledOn(pause.habit, day) {
+			return true
+		}
+	}
+	return false
+}
+
+// Inactive returns the IDs of the paused and archived habits among habits and
+// the pauses of all of them. Archiving ends a pause, so an archived habit only
+// excuses the days it was paused before.
+func Inactive(habits []models.Habit) (map[primitive.ObjectID]bool, Excuses) {
+	inactive := make(map[primitive.ObjectID]bool)
+	var excuses Excuses
+	for _, habit := range habits {
+		if habit.Status == models.HabitStatusPaused || habit.Status == models.HabitStatusArchived {
+			inactive[habit.ID] = true
+		}
+
+		for _, span := range habit.Pauses {
+			p := pause{habit: habit, start: span.Start.Format(dateLayout)}
+			if span.End != nil {
+				p.end = span.End.Format(dateLayout)
+			}
+			excuses = append(excuses, p)
+		}
+	}
+	return inactive, excuses
+}
+
+// ExcludeHabitTasks drops tasks belonging to the given habits
+func ExcludeHabitTasks(tasks []models.Task, habitIDs map[primitive.ObjectID]bool) []models.Task {
+	if len(habitIDs) == 0 {
+		return tasks
+	}
+	kept := make([]models.Task, 0, len(tasks))
+	for _, task := range tasks {
+		if task.HabitID != nil && habitIDs[*task.HabitID] {
+			continue
+		}
+		kept = append(kept, task)
+	}
+	return kept
+}
+
+// SortedDays returns the dates of the grouped tasks in ascending order
+func SortedDays(dateTasks map[string]DayInfo) []string {
+	days := make([]string, 0, len(dateTasks))
+	for day := range dateTasks {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+	return days
+}
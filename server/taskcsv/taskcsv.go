@@ -0,0 +1,269 @@
+// Package taskcsv converts tasks to and from CSV. The column set is fixed so
+// spreadsheets built on an export keep working across versions; new columns
+// are only ever added at the end.
+package taskcsv
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"habit-tracker/server/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// dateLayout is the YYYY-MM-DD format used for task dates
+const dateLayout = "2006-01-02"
+
+// Columns is the header of an exported file
+var Columns = []string{"id", "date", "name", "completed", "amount", "habit_id", "position", "note", "checklist", "created_at"}
+
+// Checklist items are written one per line, prefixed with their state
+const (
+	itemDone = "[x] "
+	itemOpen = "[ ] "
+)
+
+// ErrMissingColumns is returned when an imported file lacks the date or name
+// column
+var ErrMissingColumns = errors.New("the header must include the date and name columns")
+
+// Key identifies a task when imports look for duplicates: its name, ignoring
+// case and surrounding spaces, and its day
+func Key(name, date string) string {
+	if len(date) > len(dateLayout) {
+		date = date[:len(dateLayout)]
+	}
+	return strings.ToLower(strings.TrimSpace(name)) + "|" + date
+}
+
+// Record returns the CSV fields of a task, in the order of Columns
+func Record(task models.Task) []string {
+	habitID := ""
+	if task.HabitID != nil {
+		habitID = task.HabitID.Hex()
+	}
+	amount := ""
+	if task.Amount != nil {
+		amount = strconv.FormatFloat(*task.Amount, 'f', -1, 64)
+	}
+
+	items := make([]string, 0, len(task.Items))
+	for _, item := range task.Items {
+		if item.Completed {
+			items = append(items, itemDone+item.Name)
+		} else {
+			items = append(items, itemOpen+item.Name)
+		}
+	}
+
+	date := task.Date
+	if len(date) > len(dateLayout) {
+		date = date[:len(dateLayout)]
+	}
+
+	return []string{
+		task.ID.Hex(),
+		date,
+		task.Name,
+		strconv.FormatBool(task.Completed),
+		amount,
+		habitID,
+		strconv.Itoa(task.Position),
+		task.Note,
+		strings.Join(items, "\n"),
+		task.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// Row is a parsed line of an imported file. Line is the 1-based line number of
+// the row, counting the header. Task is only usable when Errors is empty.
+type Row struct {
+	Line   int
+	Task   models.Task
+	Errors []string
+}
+
+// Read parses an imported file. The header decides which columns are read, in
+// any order; date and name are required. The id, position and created_at
+// columns are ignored since imported tasks are new. Unknown columns are
+// returned so they can be reported.
+func Read(r io.Reader) ([]Row, []string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, ErrMissingColumns
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	index := make(map[string]int, len(header))
+	unknown := []string{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !isColumn(name) {
+			unknown = append(unknown, name)
+			continue
+		}
+		index[name] = i
+	}
+	if _, ok := index["date"]; !ok {
+		return nil, nil, ErrMissingColumns
+	}
+	if _, ok := index["name"]; !ok {
+		return nil, nil, ErrMissingColumns
+	}
+
+	rows := []Row{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, nil, err
+			}
+			rows = append(rows, Row{Line: parseErr.StartLine, Errors: []string{parseErr.Err.Error()}})
+			continue
+		}
+		if isBlank(record) {
+			continue
+		}
+
+		field := func(name string) string {
+			if i, ok := index[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		line, _ := reader.FieldPos(0)
+		rows = append(rows, parseRow(line, field))
+	}
+
+	return rows, unknown, nil
+}
+
+// parseRow validates the fields of a row and builds its task
+func parseRow(line int, field func(string) string) Row {
+	row := Row{Line: line}
+	fail := func(format string, args ...interface{}) {
+		row.Errors = append(row.Errors, fmt.Sprintf(format, args...))
+	}
+
+	row.Task.Name = field("name")
+	if row.Task.Name == "" {
+		fail("name is required")
+	}
+
+	row.Task.Date = field("date")
+	if len(row.Task.Date) > len(dateLayout) {
+		if _, err := time.Parse(time.RFC3339, row.Task.Date); err == nil {
+			row.Task.Date = row.Task.Date[:len(dateLayout)]
+		}
+	}
+	if _, err := time.Parse(dateLayout, row.Task.Date); err != nil {
+		fail("date must be in YYYY-MM-DD format")
+	}
+
+	if value := field("completed"); value != "" {
+		completed, ok := parseBool(value)
+		if !ok {
+			fail("completed must be true or false")
+		}
+		row.Task.Completed = completed
+	}
+
+	if value := field("amount"); value != "" {
+		amount, err := strconv.ParseFloat(value, 64)
+		if err != nil || amount < 0 {
+			fail("amount must be a non-negative number")
+		} else {
+			row.Task.Amount = &amount
+		}
+	}
+
+	if value := field("habit_id"); value != "" {
+		habitID, err := primitive.ObjectIDFromHex(value)
+		if err != nil {
+			fail("habit_id is not a valid ID")
+		} else {
+			row.Task.HabitID = &habitID
+		}
+	}
+
+	row.Task.Note = field("note")
+
+	for _, entry := range strings.Split(field("checklist"), "\n") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		item := models.ChecklistItem{ID: primitive.NewObjectID(), Name: entry}
+		if prefix := strings.ToLower(entry[:min(len(itemDone), len(entry))]); prefix == itemDone || prefix == itemOpen {
+			item.Completed = prefix == itemDone
+			item.Name = strings.TrimSpace(entry[len(itemDone):])
+		}
+		if item.Name == "" {
+			fail("checklist items need a name")
+			continue
+		}
+		row.Task.Items = append(row.Task.Items, item)
+	}
+	if len(row.Task.Items) > 0 {
+		row.Task.Completed = allDone(row.Task.Items)
+	}
+
+	return row
+}
+
+// isColumn reports whether name is one of Columns
+func isColumn(name string) bool {
+	for _, column := range Columns {
+		if column == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isBlank reports whether every field of a record is empty
+func isBlank(record []string) bool {
+	for _, field := range record {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// parseBool accepts the spellings spreadsheets commonly use for booleans
+func parseBool(value string) (bool, bool) {
+	switch strings.ToLower(value) {
+	case "true", "yes", "y", "1", "x":
+		return true, true
+	case "false", "no", "n", "0":
+		return false, true
+	}
+	return false, false
+}
+
+// allDone reports whether every checklist item is completed
+func allDone(items []models.ChecklistItem) bool {
+	for _, item := range items {
+		if !item.Completed {
+			return false
+		}
+	}
+	return true
+}
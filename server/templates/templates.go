@@ -0,0 +1,59 @@
+package templates
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+)
+
+// Template describes a habit that can be created from the catalog
+type Template struct {
+	Name       string  `json:"name"`
+	Type       string  `json:"type,omitempty"`
+	Category   string  `json:"category"`
+	Difficulty string  `json:"difficulty,omitempty"`
+	Weekdays   []int   `json:"weekdays,omitempty"`
+	Target     float64 `json:"target,omitempty"`
+	Unit       string  `json:"unit,omitempty"`
+}
+
+// Pack groups templates that are set up together. In a stacked pack every
+// build habit is chained after the previous one.
+type Pack struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Stacked     bool       `json:"stacked"`
+	Templates   []Template `json:"templates"`
+}
+
+//go:embed catalog.json
+var catalogJSON []byte
+
+// packs is the catalog parsed from catalog.json
+var packs []Pack
+
+func init() {
+	var catalog struct {
+		Packs []Pack `json:"packs"`
+	}
+	if err := json.Unmarshal(catalogJSON, &catalog); err != nil {
+		panic(fmt.Sprintf("templates: invalid catalog.json: %v", err))
+	}
+	packs = catalog.Packs
+}
+
+// Packs returns every pack in the catalog
+func Packs() []Pack {
+	return packs
+}
+
+// FindPack returns the pack with the given ID
+func FindPack(id string) (Pack, bool) {
+	for _, pack := range packs {
+		if pack.ID == id {
+			return pack, true
+		}
+	}
+	return Pack{}, false
+}
@@ -0,0 +1,66 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"syscall"
+)
+
+// ErrPrivateAddress is returned for webhook hosts that are loopback, private,
+// link-local or otherwise not on the public internet
+var ErrPrivateAddress = errors.New("webhook URL must point to a public address")
+
+// sharedAddressSpace is the carrier-grade NAT range, 100.64.0.0/10
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// isPublic reports whether ip is a public unicast address
+func isPublic(ip net.IP) bool {
+	return !(ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() ||
+		ip.IsUnspecified() ||
+		sharedAddressSpace.Contains(ip))
+}
+
+// CheckHost resolves a webhook host and fails unless all of its addresses are
+// public
+func CheckHost(ctx context.Context, host string) error {
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, address := range addresses {
+		if !isPublic(address.IP) {
+			return ErrPrivateAddress
+		}
+	}
+	return nil
+}
+
+// dialPublic refuses connections to addresses that are not public. It runs on
+// the resolved address right before connecting, so a host that resolves to an
+// internal address after it was validated, or a redirect to one, is refused too.
+func dialPublic(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !isPublic(ip) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// newClient returns an HTTP client that only connects to public addresses.
+// Proxies are not used, since the dialer would only see the proxy's address.
+func newClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{Timeout: requestTimeout, Control: dialPublic}).DialContext
+	return &http.Client{Timeout: requestTimeout, Transport: transport}
+}
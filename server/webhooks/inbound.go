@@ -0,0 +1,101 @@
+package webhooks
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"habit-tracker/server/models"
+)
+
+// DeliveryHeaders are request headers senders commonly use to identify a
+// delivery, checked in order. Without one, the payload itself is the key.
+var DeliveryHeaders = []string{"Idempotency-Key", "X-Delivery-ID", "X-GitHub-Delivery", "X-Request-ID"}
+
+// DeliveryKey returns the key that identifies replays of an inbound event
+func DeliveryKey(headerValue string, body []byte) string {
+	if headerValue != "" {
+		return "header:" + headerValue
+	}
+	sum := sha256.Sum256(body)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Lookup follows a dotted path such as "repository.name" or "commits.0.id"
+// through a decoded JSON document
+func Lookup(document interface{}, path string) (interface{}, bool) {
+	current := document
+	for _, segment := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[segment]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			current = node[index]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// Matches reports whether a rule applies to a decoded payload. Values are
+// compared as text, so "true", "42" and "main" all match their JSON values.
+func Matches(rule models.InboundRule, document interface{}) bool {
+	value, ok := Lookup(document, rule.Path)
+	if !ok || value == nil {
+		return false
+	}
+	if rule.Equals == "" {
+		return true
+	}
+	return strings.EqualFold(textValue(value), rule.Equals)
+}
+
+// RuleDay returns the day a matched rule completes its habit: the date found at
+// the rule's DatePath, converted to location, or the day of received
+func RuleDay(rule models.InboundRule, document interface{}, received time.Time, location *time.Location) (time.Time, error) {
+	local := received.In(location)
+	if rule.DatePath == "" {
+		return local, nil
+	}
+
+	value, ok := Lookup(document, rule.DatePath)
+	if !ok {
+		return time.Time{}, fmt.Errorf("no date at %q", rule.DatePath)
+	}
+	text := textValue(value)
+	if at, err := time.Parse(time.RFC3339, text); err == nil {
+		return at.In(location), nil
+	}
+	if day, err := time.ParseInLocation("2006-01-02", text, location); err == nil {
+		return day, nil
+	}
+	if seconds, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return time.Unix(seconds, 0).In(location), nil
+	}
+	return time.Time{}, fmt.Errorf("value at %q is not a date: %q", rule.DatePath, text)
+}
+
+// textValue renders a decoded JSON value as text
+func textValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return fmt.Sprint(value)
+}
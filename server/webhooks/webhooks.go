@@ -0,0 +1,283 @@
+// Package webhooks queues habit events for user-configured endpoints and
+// delivers them with HMAC signatures, retrying failures with exponential backoff.
+//
+// Each request carries these headers:
+//
+//	X-Habit-Event:     the event type, e.g. task.completed
+//	X-Habit-Delivery:  the delivery ID, stable across retries
+//	X-Habit-Timestamp: Unix seconds when the request was signed
+//	X-Habit-Signature: sha256=<hex HMAC-SHA256 of "<timestamp>.<body>" keyed with the webhook secret>
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"habit-tracker/server/db"
+	"habit-tracker/server/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Retry policy: the first retry waits baseBackoff and each further one twice
+// as long, up to maxBackoff. A delivery fails for good after MaxAttempts.
+const (
+	MaxAttempts = 8
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 6 * time.Hour
+)
+
+// claimTimeout is how long a claimed delivery is hidden from other workers
+// while it is being sent
+const claimTimeout = time.Minute
+
+// requestTimeout bounds a single HTTP request to a webhook
+const requestTimeout = 10 * time.Second
+
+// Events lists the event types a webhook can subscribe to
+var Events = []string{
+	models.EventTaskCreated,
+	models.EventTaskCompleted,
+	models.EventStreakBroken,
+	models.EventAchievementUnlocked,
+}
+
+// Client sends webhook requests, to public addresses only
+var Client = newClient()
+
+// Payload is the JSON body sent to a webhook
+type Payload struct {
+	ID        string      `json:"id"`
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// IsEvent reports whether event is a known event type
+func IsEvent(event string) bool {
+	for _, known := range Events {
+		if known == event {
+			return true
+		}
+	}
+	return false
+}
+
+// NewSecret generates a random signing secret
+func NewSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Sign returns the signature header value for a request body
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff returns how long to wait before the next attempt after the given
+// number of failed attempts
+func Backoff(failures int) time.Duration {
+	wait := baseBackoff
+	for i := 1; i < failures; i++ {
+		wait *= 2
+		if wait >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return wait
+}
+
+// NewDelivery builds a pending delivery of an event to a webhook
+func NewDelivery(webhook models.Webhook, event string, data interface{}) (models.WebhookDelivery, error) {
+	now := time.Now()
+	delivery := models.WebhookDelivery{
+		ID:            primitive.NewObjectID(),
+		WebhookID:     webhook.ID,
+		UserID:        webhook.UserID,
+		Event:         event,
+		Status:        models.DeliveryPending,
+		Attempts:      []models.WebhookAttempt{},
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+
+	body, err := json.Marshal(Payload{ID: delivery.ID.Hex(), Event: event, CreatedAt: now, Data: data})
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	delivery.Payload = string(body)
+	return delivery, nil
+}
+
+// Publish queues an event for every active webhook of the user subscribed to it
+func Publish(userID primitive.ObjectID, event string, data interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := db.WebhookColl.Find(ctx, bson.M{"user_id": userID, "events": event, "active": true})
+	if err != nil {
+		return err
+	}
+	var webhooks []models.Webhook
+	if err := cursor.All(ctx, &webhooks); err != nil {
+		return err
+	}
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	deliveries := make([]interface{}, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		delivery, err := NewDelivery(webhook, event, data)
+		if err != nil {
+			return err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	_, err = db.DeliveryColl.InsertMany(ctx, deliveries)
+	return err
+}
+
+// Send makes one signed request for a delivery. Any 2xx response is a success.
+func Send(ctx context.Context, webhook models.Webhook, delivery models.WebhookDelivery) models.WebhookAttempt {
+	started := time.Now()
+	attempt := models.WebhookAttempt{At: started}
+
+	body := []byte(delivery.Payload)
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	timestamp := started.Unix()
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "habit-tracker-webhooks/1")
+	request.Header.Set("X-Habit-Event", delivery.Event)
+	request.Header.Set("X-Habit-Delivery", delivery.ID.Hex())
+	request.Header.Set("X-Habit-Timestamp", strconv.FormatInt(timestamp, 10))
+	request.Header.Set("X-Habit-Signature", Sign(webhook.Secret, timestamp, body))
+
+	response, err := Client.Do(request)
+	attempt.DurationMS = time.Since(started).Milliseconds()
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+
+	attempt.StatusCode = response.StatusCode
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		attempt.Error = fmt.Sprintf("unexpected status %s", response.Status)
+	}
+	return attempt
+}
+
+// DeliverDue sends up to limit queued deliveries that are due and returns how
+// many were attempted. Each delivery is claimed atomically, so several server
+// instances can work the queue at once.
+func DeliverDue(now time.Time, limit int) (int, error) {
+	attempted := 0
+	for attempted < limit {
+		delivery, err := claimDue(now)
+		if err == mongo.ErrNoDocuments {
+			return attempted, nil
+		}
+		if err != nil {
+			return attempted, err
+		}
+
+		if err := deliver(delivery); err != nil {
+			return attempted, err
+		}
+		attempted++
+	}
+	return attempted, nil
+}
+
+// claimDue takes the oldest due delivery off the queue by pushing its next
+// attempt past claimTimeout. If the worker dies mid-send it becomes due again.
+func claimDue(now time.Time) (models.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var delivery models.WebhookDelivery
+	err := db.DeliveryColl.FindOneAndUpdate(
+		ctx,
+		bson.M{"status": models.DeliveryPending, "next_attempt_at": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"next_attempt_at": now.Add(claimTimeout)}},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+			SetReturnDocument(options.After),
+	).Decode(&delivery)
+	return delivery, err
+}
+
+// deliver sends a claimed delivery and records the attempt, scheduling a retry
+// on failure
+func deliver(delivery models.WebhookDelivery) error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout+10*time.Second)
+	defer cancel()
+
+	var webhook models.Webhook
+	err := db.WebhookColl.FindOne(ctx, bson.M{"_id": delivery.WebhookID}).Decode(&webhook)
+	var attempt models.WebhookAttempt
+	switch {
+	case err == mongo.ErrNoDocuments:
+		attempt = models.WebhookAttempt{At: time.Now(), Error: "webhook was deleted"}
+	case err != nil:
+		return err
+	case !webhook.Active:
+		attempt = models.WebhookAttempt{At: time.Now(), Error: "webhook is disabled"}
+	default:
+		attempt = Send(ctx, webhook, delivery)
+	}
+
+	return Record(ctx, delivery, attempt, err == nil && webhook.Active)
+}
+
+// Record appends an attempt to a delivery and moves it on: delivered on
+// success, retried later on failure, or failed once retries are exhausted or
+// retrying makes no sense
+func Record(ctx context.Context, delivery models.WebhookDelivery, attempt models.WebhookAttempt, retry bool) error {
+	_, err := db.DeliveryColl.UpdateOne(
+		ctx,
+		bson.M{"_id": delivery.ID},
+		bson.M{"$set": nextState(delivery, attempt, retry), "$push": bson.M{"attempts": attempt}},
+	)
+	return err
+}
+
+// nextState returns the fields Record sets on a delivery after an attempt
+func nextState(delivery models.WebhookDelivery, attempt models.WebhookAttempt, retry bool) bson.M {
+	set := bson.M{}
+	switch failures := len(delivery.Attempts) + 1; {
+	case attempt.Error == "":
+		set["status"] = models.DeliveryDelivered
+		set["delivered_at"] = attempt.At
+	case retry && failures < MaxAttempts:
+		set["next_attempt_at"] = attempt.At.Add(Backoff(failures))
+	default:
+		set["status"] = models.DeliveryFailed
+	}
+	return set
+}
@@ -0,0 +1,186 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"habit-tracker/server/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSign(t *testing.T) {
+	got := Sign("whsec_test", 1700000000, []byte(`{"event":"ping"}`))
+	want := "sha256=aa8efe37b751e71157c508c5ac4acb1e9fe5225db98355dfc00f4b680afbc447"
+	if got != want {
+		t.Errorf("Sign() = %s, want %s", got, want)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{10, 256 * time.Minute},
+		{11, maxBackoff},
+		{MaxAttempts + 100, maxBackoff},
+	}
+	for _, test := range tests {
+		if got := Backoff(test.failures); got != test.want {
+			t.Errorf("Backoff(%d) = %s, want %s", test.failures, got, test.want)
+		}
+	}
+}
+
+// useClient swaps the webhook client for the duration of a test. The default
+// client refuses the loopback addresses test servers listen on.
+func useClient(t *testing.T, client *http.Client) {
+	previous := Client
+	Client = client
+	t.Cleanup(func() { Client = previous })
+}
+
+// testDelivery builds a delivery of a ping to url
+func testDelivery(t *testing.T, url string) (models.Webhook, models.WebhookDelivery) {
+	webhook := models.Webhook{ID: primitive.NewObjectID(), URL: url, Secret: "whsec_test", Active: true}
+	delivery, err := NewDelivery(webhook, models.EventPing, map[string]string{"hello": "world"})
+	if err != nil {
+		t.Fatalf("NewDelivery failed: %v", err)
+	}
+	return webhook, delivery
+}
+
+func TestSendSignsRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, err := strconv.ParseInt(r.Header.Get("X-Habit-Timestamp"), 10, 64)
+		if err != nil {
+			t.Errorf("invalid timestamp header: %v", err)
+		}
+		if got, want := r.Header.Get("X-Habit-Signature"), Sign("whsec_test", timestamp, body); got != want {
+			t.Errorf("signature = %s, want %s", got, want)
+		}
+		if got := r.Header.Get("X-Habit-Event"); got != models.EventPing {
+			t.Errorf("event header = %s, want %s", got, models.EventPing)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	useClient(t, server.Client())
+
+	webhook, delivery := testDelivery(t, server.URL)
+	attempt := Send(context.Background(), webhook, delivery)
+	if attempt.Error != "" || attempt.StatusCode != http.StatusNoContent {
+		t.Fatalf("Send() = %+v, want a 204 without error", attempt)
+	}
+
+	set := nextState(delivery, attempt, true)
+	if set["status"] != models.DeliveryDelivered {
+		t.Errorf("status = %v, want %s", set["status"], models.DeliveryDelivered)
+	}
+}
+
+func TestSendRetriesServerErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	useClient(t, server.Client())
+
+	webhook, delivery := testDelivery(t, server.URL)
+	attempt := Send(context.Background(), webhook, delivery)
+	if attempt.Error == "" || attempt.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Send() = %+v, want a failed 503", attempt)
+	}
+
+	set := nextState(delivery, attempt, true)
+	if _, ok := set["status"]; ok {
+		t.Errorf("status = %v, want the delivery to stay pending", set["status"])
+	}
+	if got, want := set["next_attempt_at"], attempt.At.Add(baseBackoff); got != want {
+		t.Errorf("next_attempt_at = %v, want %v", got, want)
+	}
+
+	delivery.Attempts = make([]models.WebhookAttempt, MaxAttempts-1)
+	if set := nextState(delivery, attempt, true); set["status"] != models.DeliveryFailed {
+		t.Errorf("status after %d attempts = %v, want %s", MaxAttempts, set["status"], models.DeliveryFailed)
+	}
+}
+
+func TestSendTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := server.Client()
+	client.Timeout = 50 * time.Millisecond
+	useClient(t, client)
+
+	webhook, delivery := testDelivery(t, server.URL)
+	attempt := Send(context.Background(), webhook, delivery)
+	if attempt.Error == "" || attempt.StatusCode != 0 {
+		t.Fatalf("Send() = %+v, want a timeout without status", attempt)
+	}
+	if set := nextState(delivery, attempt, true); set["next_attempt_at"] == nil {
+		t.Errorf("a timed out delivery should be retried, got %v", set)
+	}
+}
+
+func TestSendRefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached a loopback address")
+	}))
+	defer server.Close()
+
+	webhook, delivery := testDelivery(t, server.URL)
+	attempt := Send(context.Background(), webhook, delivery)
+	if !strings.Contains(attempt.Error, ErrPrivateAddress.Error()) {
+		t.Errorf("Send() error = %q, want %q", attempt.Error, ErrPrivateAddress)
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+	tests := map[string]bool{
+		"93.184.216.34":   true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"::1":             false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"fe80::1":         false,
+		"fd00::1":         false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"::ffff:10.0.0.1": false,
+	}
+	for address, want := range tests {
+		if got := isPublic(net.ParseIP(address)); got != want {
+			t.Errorf("isPublic(%s) = %t, want %t", address, got, want)
+		}
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	for _, host := range []string{"127.0.0.1", "localhost", "169.254.169.254"} {
+		if err := CheckHost(context.Background(), host); !errors.Is(err, ErrPrivateAddress) {
+			t.Errorf("CheckHost(%s) = %v, want ErrPrivateAddress", host, err)
+		}
+	}
+}